@@ -15,11 +15,9 @@ _imlazy_completions() {
     # Built-in commands
     local builtins="help how init version"
 
-    # Get commands from lazy.toml if it exists
+    # Get public commands from lazy.toml (private commands are hidden)
     local commands=""
-    if [[ -f lazy.toml ]]; then
-        commands=$(grep -E '^\[commands\.' lazy.toml | sed 's/\[commands\.\(.*\)\]/\1/' | tr '\n' ' ')
-    fi
+    commands=$(imlazy list --plain 2>/dev/null | cut -f1 | tr '\n' ' ')
 
     # Options
     local opts="-n --dry-run -q --quiet -V --verbose -v --version -h --help"
@@ -73,22 +71,17 @@ _imlazy() {
         'version:Show version information'
         'watch:Watch files and re-run command on changes'
         'validate:Validate lazy.toml configuration'
+        'ui:Open a dashboard that runs commands'
+        'doctor:Diagnose config and environment'
+        'history:Show recent runs'
         'completion:Generate shell completion script'
     )
 
-    # Get commands from lazy.toml if it exists
-    if [[ -f lazy.toml ]]; then
-        local cmd desc
-        while IFS= read -r line; do
-            if [[ "$line" =~ ^\[commands\.([^\]]+)\] ]]; then
-                cmd="${match[1]}"
-            elif [[ "$line" =~ ^desc[[:space:]]*=[[:space:]]*\"(.*)\" && -n "$cmd" ]]; then
-                desc="${match[1]}"
-                commands+=("$cmd:$desc")
-                cmd=""
-            fi
-        done < lazy.toml
-    fi
+    # Get public commands from lazy.toml (private commands are hidden)
+    local cmd desc
+    while IFS=$'\t' read -r cmd desc; do
+        [[ -n "$cmd" ]] && commands+=("${cmd//:/\\:}:$desc")
+    done < <(imlazy list --plain 2>/dev/null)
 
     _arguments -s \
         $options \
@@ -128,13 +121,14 @@ complete -c imlazy -n '__fish_use_subcommand' -a 'init' -d 'Create a new lazy.to
 complete -c imlazy -n '__fish_use_subcommand' -a 'version' -d 'Show version information'
 complete -c imlazy -n '__fish_use_subcommand' -a 'watch' -d 'Watch files and re-run command'
 complete -c imlazy -n '__fish_use_subcommand' -a 'validate' -d 'Validate lazy.toml configuration'
+complete -c imlazy -n '__fish_use_subcommand' -a 'ui' -d 'Open a dashboard that runs commands'
+complete -c imlazy -n '__fish_use_subcommand' -a 'doctor' -d 'Diagnose config and environment'
+complete -c imlazy -n '__fish_use_subcommand' -a 'history' -d 'Show recent runs'
 complete -c imlazy -n '__fish_use_subcommand' -a 'completion' -d 'Generate shell completion script'
 
-# Dynamic command completion from lazy.toml
+# Dynamic command completion from lazy.toml (private commands are hidden)
 function __imlazy_commands
-    if test -f lazy.toml
-        grep -E '^\[commands\.' lazy.toml | sed 's/\[commands\.\(.*\)\]/\1/'
-    end
+    imlazy list --plain 2>/dev/null
 end
 
 complete -c imlazy -n '__fish_use_subcommand' -a '(__imlazy_commands)' -d 'Command from lazy.toml'
@@ -0,0 +1,370 @@
+// Package glob matches file paths against glob patterns. Patterns use forward
+// slashes and support:
+//
+//	**       zero or more whole path segments
+//	*        any run of characters except /
+//	?        any single character except /
+//	[a-z]    character classes ([!a-z] or [^a-z] negates)
+//	{a,b}    alternation (may be nested)
+//	!pat     negation, when used in a pattern list
+//
+// Hidden directories (names starting with ".") are only matched by pattern
+// segments that start with "." themselves, so "**/*.go" does not descend into
+// .git while ".github/**/*.yml" still works.
+package glob
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrBadPattern is returned for malformed patterns
+var ErrBadPattern = errors.New("syntax error in pattern")
+
+// Match reports whether name matches the pattern. A leading "!" is ignored;
+// use MatchAny to apply negation.
+func Match(pattern, name string) bool {
+	return match(pattern, name, false)
+}
+
+// MatchHidden is like Match, but * and ** also match hidden directories, as
+// in .gitignore files
+func MatchHidden(pattern, name string) bool {
+	return match(pattern, name, true)
+}
+
+func match(pattern, name string, hidden bool) bool {
+	pattern = strings.TrimPrefix(pattern, "!")
+	nameSegs := splitPath(name)
+	for _, alt := range expandBraces(pattern) {
+		if matchSegments(splitPath(alt), nameSegs, hidden) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchAny reports whether name matches at least one positive pattern and no
+// negative ("!") pattern
+func MatchAny(patterns []string, name string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			if Match(pattern, name) {
+				return false
+			}
+		} else if !matched && Match(pattern, name) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// CouldMatchBelow reports whether any positive pattern could match a path
+// inside dir (a slash-separated path relative to the patterns' root)
+func CouldMatchBelow(patterns []string, dir string) bool {
+	dirSegs := splitPath(dir)
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			continue
+		}
+		for _, alt := range expandBraces(pattern) {
+			if matchDirPrefix(splitPath(alt), dirSegs) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasMeta reports whether the pattern contains any glob syntax
+func HasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[{\`) || strings.HasPrefix(pattern, "!")
+}
+
+// Validate checks the pattern for syntax errors
+func Validate(pattern string) error {
+	pattern = strings.TrimPrefix(pattern, "!")
+	depth := 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth < 0 {
+				return ErrBadPattern
+			}
+		}
+	}
+	if depth != 0 {
+		return ErrBadPattern
+	}
+	for _, alt := range expandBraces(pattern) {
+		for _, seg := range splitPath(alt) {
+			if _, err := path.Match(pathPattern(seg), ""); err != nil {
+				return ErrBadPattern
+			}
+		}
+	}
+	return nil
+}
+
+// SplitPattern splits a pattern into the longest leading directory without
+// glob syntax and the remaining pattern
+func SplitPattern(pattern string) (base, rest string) {
+	segs := strings.Split(pattern, "/")
+	i := 0
+	for i < len(segs)-1 && !HasMeta(segs[i]) {
+		i++
+	}
+	base = strings.Join(segs[:i], "/")
+	if base == "" && strings.HasPrefix(pattern, "/") {
+		base = "/"
+	}
+	return base, strings.Join(segs[i:], "/")
+}
+
+// Relative rewrites absolute patterns so they are relative to root, keeping
+// any "!" prefix. Relative patterns are returned unchanged.
+func Relative(root string, patterns []string) []string {
+	result := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		p := filepath.ToSlash(strings.TrimPrefix(pattern, "!"))
+		if strings.HasPrefix(p, "/") {
+			base, rest := SplitPattern(p)
+			if relBase, err := filepath.Rel(root, filepath.FromSlash(base)); err == nil {
+				p = path.Join(filepath.ToSlash(relBase), rest)
+			}
+		}
+		if negate {
+			p = "!" + p
+		}
+		result[i] = p
+	}
+	return result
+}
+
+// Roots returns the directories to walk to find everything the patterns can
+// match: root itself plus the base of any pattern that reaches outside it
+func Roots(root string, patterns []string) []string {
+	roots := []string{root}
+	seen := map[string]bool{root: true}
+	for _, pattern := range Relative(root, patterns) {
+		if strings.HasPrefix(pattern, "!") {
+			continue
+		}
+		base, _ := SplitPattern(pattern)
+		if base != ".." && !strings.HasPrefix(base, "../") {
+			continue
+		}
+		dir := filepath.Join(root, filepath.FromSlash(base))
+		if !seen[dir] {
+			seen[dir] = true
+			roots = append(roots, dir)
+		}
+	}
+	return roots
+}
+
+// Files returns the files matching the patterns (see MatchAny), sorted and as
+// absolute paths. Relative patterns are resolved against root. Directories that
+// can't contain a match are not walked; skip may exclude further paths, e.g.
+// ignored ones.
+func Files(root string, patterns []string, skip func(path string, isDir bool) bool) ([]string, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	patterns = Relative(root, patterns)
+
+	seen := make(map[string]bool)
+	var files []string
+	for _, start := range Roots(root, patterns) {
+		err := filepath.Walk(start, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil // Skip unreadable entries
+			}
+			rel, _ := filepath.Rel(root, p)
+			rel = filepath.ToSlash(rel)
+			if info.IsDir() {
+				if p == start {
+					return nil
+				}
+				if !CouldMatchBelow(patterns, rel) || (skip != nil && skip(p, true)) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !seen[p] && MatchAny(patterns, rel) && (skip == nil || !skip(p, false)) {
+				seen[p] = true
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// splitPath splits a slash-separated path into segments, dropping empty and "." ones
+func splitPath(p string) []string {
+	var segs []string
+	for _, seg := range strings.Split(filepath.ToSlash(p), "/") {
+		if seg != "" && seg != "." {
+			segs = append(segs, seg)
+		}
+	}
+	return segs
+}
+
+// matchSegments matches pattern segments against path segments. Unless hidden
+// is set, hidden directories need an explicit dot.
+func matchSegments(pat, name []string, hidden bool) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			// Collapse repeated ** segments
+			for len(pat) > 0 && pat[0] == "**" {
+				pat = pat[1:]
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pat, name[i:], hidden) {
+					return true
+				}
+				// ** never crosses into hidden directories
+				if !hidden && i < len(name)-1 && isHidden(name[i]) {
+					return false
+				}
+			}
+			return false
+		}
+		if len(name) == 0 || !matchSegment(pat[0], name[0], !hidden && len(name) > 1) {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchDirPrefix reports whether a path below dir could still match pat
+func matchDirPrefix(pat, dir []string) bool {
+	for len(dir) > 0 {
+		if len(pat) == 0 {
+			return false
+		}
+		if pat[0] == "**" {
+			// ** can continue below any directory that isn't hidden
+			for _, seg := range dir {
+				if isHidden(seg) {
+					return matchDirPrefix(pat[1:], dir)
+				}
+			}
+			return true
+		}
+		if !matchSegment(pat[0], dir[0], true) {
+			return false
+		}
+		pat, dir = pat[1:], dir[1:]
+	}
+	return len(pat) > 0
+}
+
+// matchSegment matches a single segment; hidden directories need an explicit dot
+func matchSegment(pat, seg string, isDir bool) bool {
+	if isDir && isHidden(seg) && !strings.HasPrefix(pat, ".") {
+		return false
+	}
+	matched, _ := path.Match(pathPattern(pat), seg)
+	return matched
+}
+
+// pathPattern rewrites "[!...]" classes to the "[^...]" path.Match understands
+func pathPattern(pat string) string {
+	if !strings.Contains(pat, "[!") {
+		return pat
+	}
+	var b strings.Builder
+	inClass := false
+	for i := 0; i < len(pat); i++ {
+		switch {
+		case pat[i] == '\\' && i+1 < len(pat):
+			b.WriteByte(pat[i])
+			i++
+		case pat[i] == '[' && !inClass:
+			inClass = true
+			if i+1 < len(pat) && pat[i+1] == '!' {
+				b.WriteString("[^")
+				i++
+				continue
+			}
+		case pat[i] == ']':
+			inClass = false
+		}
+		b.WriteByte(pat[i])
+	}
+	return b.String()
+}
+
+func isHidden(seg string) bool {
+	return strings.HasPrefix(seg, ".") && seg != "." && seg != ".."
+}
+
+// expandBraces expands {a,b} alternations, including nested ones
+func expandBraces(pattern string) []string {
+	start, end := -1, -1
+	depth := 0
+	var commas []int
+	for i := 0; i < len(pattern) && end < 0; i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				start = i
+				commas = nil
+			}
+			depth++
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth == 0 {
+				if len(commas) == 0 {
+					// "{a}" is not an alternation; keep looking after it
+					start = -1
+					continue
+				}
+				end = i
+			}
+		}
+	}
+	if start < 0 || end < 0 {
+		return []string{pattern}
+	}
+
+	prefix, suffix := pattern[:start], pattern[end+1:]
+	var results []string
+	prev := start + 1
+	for _, comma := range append(commas, end) {
+		for _, expanded := range expandBraces(prefix + pattern[prev:comma] + suffix) {
+			results = append(results, expanded)
+		}
+		prev = comma + 1
+	}
+	return results
+}
@@ -0,0 +1,210 @@
+package glob
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "parser/parser.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "parser/parser.go", true},
+		{"**/*.go", "deep/nested/file.go", true},
+		{"**/*.go", "file.txt", false},
+		{"src/**/*.ts", "src/index.ts", true},
+		{"src/**/*.ts", "lib/index.ts", false},
+		{"src/**/*.ts", "src/a/b/c.ts", true},
+		{"src/**", "src/a/b/c.ts", true},
+		{"**/testdata/**", "pkg/testdata/in.txt", true},
+		{"a/**/b/*.go", "a/x/y/b/z.go", true},
+		{"a/**/b/*.go", "a/b/z.go", true},
+		{"a/**/b/*.go", "a/x/c/z.go", false},
+		{"**/*.{go,mod}", "go.mod", true},
+		{"**/*.{go,mod}", "cmd/main.go", true},
+		{"**/*.{go,mod}", "README.md", false},
+		{"{cmd,internal/{a,b}}/*.go", "internal/b/x.go", true},
+		{"{cmd,internal/{a,b}}/*.go", "internal/c/x.go", false},
+		{"file?.txt", "file1.txt", true},
+		{"file[0-9].txt", "filex.txt", false},
+		{"[!a]*.go", "b.go", true},
+		{"[!a]*.go", "a.go", false},
+		{"[^a]*.go", "b.go", true},
+		{"[a!]*.go", "!.go", true}, // ! only negates at the start of a class
+		{`\[!a]*.go`, "[!a]x.go", true},
+		{"./*.go", "main.go", true},
+		{"**/*.json", ".lazy/if_changed.json", false}, // hidden directories need an explicit dot
+		{".github/**/*.yml", ".github/workflows/ci.yml", true},
+		{"*", ".env", true}, // hidden files are fine
+		{"test:*", "test:unit", true},
+		{"test:*", "build", false},
+		{"!*.go", "main.go", true}, // negation only applies in MatchAny
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"_"+tt.path, func(t *testing.T) {
+			if got := Match(tt.pattern, tt.path); got != tt.expected {
+				t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMatchAny(t *testing.T) {
+	patterns := []string{"**/*.go", "!**/*_test.go", "!vendor/**"}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"main.go", true},
+		{"parser/parser.go", true},
+		{"parser/parser_test.go", false},
+		{"vendor/lib/lib.go", false},
+		{"README.md", false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchAny(patterns, tt.path); got != tt.expected {
+			t.Errorf("MatchAny(%v, %q) = %v, want %v", patterns, tt.path, got, tt.expected)
+		}
+	}
+
+	if MatchAny([]string{"!*.md"}, "main.go") {
+		t.Error("only negative patterns should match nothing")
+	}
+}
+
+func TestMatchHidden(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{"**/*.json", ".lazy/if_changed.json", true},
+		{"*/*.log", ".cache/debug.log", true},
+		{"a/**/b", "a/.x/.y/b", true},
+		{"**/*.json", "data.txt", false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchHidden(tt.pattern, tt.path); got != tt.expected {
+			t.Errorf("MatchHidden(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestCouldMatchBelow(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		dir      string
+		expected bool
+	}{
+		{[]string{"*.go"}, "parser", false},
+		{[]string{"**/*.go"}, "a/b/c", true},
+		{[]string{"**/*.go"}, ".git", false},
+		{[]string{"src/**/*.ts"}, "src/a", true},
+		{[]string{"src/**/*.ts"}, "lib", false},
+		{[]string{"src/*.ts"}, "src", true},
+		{[]string{"src/*.ts"}, "src/a", false},
+		{[]string{"{src,lib}/*.ts"}, "lib", true},
+		{[]string{".github/**/*.yml"}, ".github/workflows", true},
+		{[]string{"!**/*.go"}, "a", false},
+	}
+
+	for _, tt := range tests {
+		if got := CouldMatchBelow(tt.patterns, tt.dir); got != tt.expected {
+			t.Errorf("CouldMatchBelow(%v, %q) = %v, want %v", tt.patterns, tt.dir, got, tt.expected)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, pattern := range []string{"**/*.go", "{a,b}/*.go", "!vendor/**", "file[0-9].txt", "[!a-z]*"} {
+		if err := Validate(pattern); err != nil {
+			t.Errorf("Validate(%q) = %v, want nil", pattern, err)
+		}
+	}
+	for _, pattern := range []string{"[a-", "[!a-", "{a,b", "a}"} {
+		if err := Validate(pattern); err == nil {
+			t.Errorf("Validate(%q) = nil, want error", pattern)
+		}
+	}
+}
+
+func TestSplitPattern(t *testing.T) {
+	tests := []struct {
+		pattern, base, rest string
+	}{
+		{"src/**/*.go", "src", "**/*.go"},
+		{"*.go", "", "*.go"},
+		{"go.mod", "", "go.mod"},
+		{"/etc/imlazy/*.toml", "/etc/imlazy", "*.toml"},
+		{"../shared/{a,b}/*.go", "../shared", "{a,b}/*.go"},
+	}
+
+	for _, tt := range tests {
+		base, rest := SplitPattern(tt.pattern)
+		if base != tt.base || rest != tt.rest {
+			t.Errorf("SplitPattern(%q) = (%q, %q), want (%q, %q)", tt.pattern, base, rest, tt.base, tt.rest)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"main.go",
+		"main_test.go",
+		"go.mod",
+		"parser/parser.go",
+		"parser/parser_test.go",
+		"vendor/lib/lib.go",
+		".git/hooks/hook.go",
+		"docs/README.md",
+	} {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := Files(root, []string{"**/*.{go,mod}", "!**/*_test.go"}, func(path string, isDir bool) bool {
+		return isDir && filepath.Base(path) == "vendor"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, file := range files {
+		rel, _ := filepath.Rel(root, file)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	expected := []string{"go.mod", "main.go", "parser/parser.go"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Files() = %v, want %v", got, expected)
+	}
+
+	// Absolute patterns and patterns outside the root are resolved too
+	files, err = Files(filepath.Join(root, "docs"), []string{filepath.Join(root, "parser", "*.go"), "../*.mod"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{
+		filepath.Join(root, "go.mod"),
+		filepath.Join(root, "parser", "parser.go"),
+		filepath.Join(root, "parser", "parser_test.go"),
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Files() = %v, want %v", files, expected)
+	}
+}
@@ -0,0 +1,183 @@
+package ignore
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/javanhut/imlazy/glob"
+)
+
+// IgnoreFiles are the per-directory files whose patterns are honored
+var IgnoreFiles = []string{".gitignore", ".lazyignore"}
+
+// rule is a single gitignore-style pattern
+type rule struct {
+	pattern string // Glob matched against the path relative to the ignore file
+	negate  bool
+	dirOnly bool
+}
+
+// Matcher decides whether paths below a root directory are ignored. It combines
+// .gitignore and .lazyignore files (loaded lazily per directory) with extra
+// patterns from the config, using gitignore syntax throughout.
+type Matcher struct {
+	root  string
+	extra []rule
+	mu    sync.Mutex
+	cache map[string][]rule // Rules from the ignore files in each directory
+}
+
+// New creates a matcher rooted at root. Extra patterns are relative to root and
+// take precedence over ignore files. A relative root is resolved against the
+// current directory, so absolute paths can be matched against it.
+func New(root string, patterns []string) *Matcher {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	m := &Matcher{
+		root:  root,
+		cache: make(map[string][]rule),
+	}
+	for _, pattern := range patterns {
+		if r, ok := parseRule(pattern); ok {
+			m.extra = append(m.extra, r)
+		}
+	}
+	return m
+}
+
+// Match reports whether path (absolute or relative to the root) is ignored,
+// either itself or because one of its parent directories is
+func (m *Matcher) Match(path string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	rel := path
+	if filepath.IsAbs(path) {
+		var err error
+		rel, err = filepath.Rel(m.root, path)
+		if err != nil {
+			return false
+		}
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return false
+	}
+
+	// A path is ignored if any of its parent directories is
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if m.matchOne(parts[:i], true) {
+			return true
+		}
+	}
+	return m.matchOne(parts, isDir)
+}
+
+// matchOne checks a single path against the rules that apply to it, without
+// looking at its parents. Later rules override earlier ones, as in git.
+func (m *Matcher) matchOne(parts []string, isDir bool) bool {
+	ignored := false
+	apply := func(rules []rule, rel string) {
+		for _, r := range rules {
+			if r.dirOnly && !isDir {
+				continue
+			}
+			if glob.MatchHidden(r.pattern, rel) {
+				ignored = !r.negate
+			}
+		}
+	}
+
+	// Ignore files from the root down to the path's own directory
+	for i := 0; i < len(parts); i++ {
+		dir := strings.Join(parts[:i], "/")
+		apply(m.dirRules(dir), strings.Join(parts[i:], "/"))
+	}
+
+	apply(m.extra, strings.Join(parts, "/"))
+	return ignored
+}
+
+// dirRules returns the rules from the ignore files in a directory (relative to root)
+func (m *Matcher) dirRules(dir string) []rule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rules, ok := m.cache[dir]; ok {
+		return rules
+	}
+
+	var rules []rule
+	for _, name := range IgnoreFiles {
+		rules = append(rules, loadFile(filepath.Join(m.root, filepath.FromSlash(dir), name))...)
+	}
+	m.cache[dir] = rules
+	return rules
+}
+
+// loadFile reads the patterns of an ignore file; missing files have no rules
+func loadFile(path string) []rule {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var rules []rule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if r, ok := parseRule(scanner.Text()); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// parseRule parses one line of gitignore syntax
+func parseRule(line string) (rule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+
+	var r rule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule{}, false
+	}
+
+	// Patterns without a slash match at any depth; others are anchored
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+	// A trailing "**" matches everything inside, but not the directory itself
+	if strings.HasSuffix(line, "/**") {
+		line += "/*"
+	}
+	// Gitignore has no brace alternation, and a "!" left here is literal
+	line = strings.NewReplacer("{", `\{`, "}", `\}`).Replace(line)
+	if strings.HasPrefix(line, "!") {
+		line = `\` + line
+	}
+	if glob.Validate(line) != nil {
+		return rule{}, false
+	}
+	r.pattern = line
+	return r, true
+}
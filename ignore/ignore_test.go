@@ -0,0 +1,73 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	root := t.TempDir()
+
+	files := map[string]string{
+		".gitignore":     "node_modules/\n/imlazy\n*.log\n!keep.log\nbuild/**/*.o\ntmp/**\n!tmp/keep\n[!a-m]*.bak\n{a,b}.txt\n",
+		".lazyignore":    "# comment\n\ndocs/\n",
+		"web/.gitignore": "dist\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := New(root, []string{"vendor/", "*.gen.go"})
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"imlazy", false, true},
+		{"cmd/imlazy", false, false}, // anchored to root
+		{"node_modules", true, true},
+		{"web/node_modules/pkg/index.js", false, true}, // parent ignored
+		{"node_modules", false, false},                 // dir-only pattern
+		{"debug.log", false, true},
+		{"logs/debug.log", false, true},
+		{"keep.log", false, false}, // negated
+		{"build/a/b/x.o", false, true},
+		{"build/x.o", false, true},
+		{".cache/x/debug.log", false, true}, // hidden directories too
+		{"tmp/a/b", false, true},
+		{"tmp/keep", false, false}, // tmp/** leaves tmp itself alone
+		{"zip.bak", false, true},
+		{"app.bak", false, false}, // negated class
+		{"{a,b}.txt", false, true},
+		{"a.txt", false, false},        // no brace alternation
+		{"docs/index.md", false, true}, // .lazyignore
+		{"web/dist/app.js", false, true},
+		{"dist/app.js", false, false}, // nested .gitignore only applies below web/
+		{"vendor/lib.go", false, true},
+		{"parser/types.gen.go", false, true},
+		{"parser/parser.go", false, false},
+		{filepath.Join(root, "imlazy"), false, true}, // absolute path
+		{"../outside", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := m.Match(tt.path, tt.isDir); got != tt.expected {
+				t.Errorf("Match(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNilMatcher(t *testing.T) {
+	var m *Matcher
+	if m.Match("anything", false) {
+		t.Error("nil matcher should not ignore anything")
+	}
+}
@@ -1,19 +1,26 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/javanhut/imlazy/completion"
+	"github.com/javanhut/imlazy/glob"
 	"github.com/javanhut/imlazy/output"
 	"github.com/javanhut/imlazy/parser"
 	"github.com/javanhut/imlazy/tui"
 	"github.com/javanhut/imlazy/watcher"
+	"golang.org/x/term"
 )
 
 var (
@@ -32,6 +39,8 @@ func main() {
 	var showVersion bool
 	var showVersionShort bool
 	var watchMode bool
+	var debounce time.Duration
+	var clearScreen bool
 	var parallelMode bool
 	var interactiveMode bool
 	var passthrough []string
@@ -67,8 +76,21 @@ func main() {
 			opts.Quiet = true
 		case "--force", "-f":
 			opts.Force = true
+		case "--yes", "-y":
+			opts.Yes = true
+		case "--all":
+			opts.All = true
 		case "--watch", "-w":
 			watchMode = true
+		case "--debounce":
+			if i+1 >= len(mainArgs) {
+				output.PrintError("--debounce requires a duration (e.g. 1s, 500ms)")
+				os.Exit(1)
+			}
+			i++
+			debounce = parseDebounce(mainArgs[i])
+		case "--clear":
+			clearScreen = true
 		case "--parallel", "-p":
 			parallelMode = true
 		case "--interactive", "-i":
@@ -82,6 +104,10 @@ func main() {
 		case "--version-short":
 			showVersionShort = true
 		default:
+			if strings.HasPrefix(arg, "--debounce=") {
+				debounce = parseDebounce(strings.TrimPrefix(arg, "--debounce="))
+				continue
+			}
 			remainingArgs = append(remainingArgs, arg)
 		}
 	}
@@ -121,6 +147,12 @@ func main() {
 		return
 	}
 
+	// Handle global history (doesn't need config)
+	if len(remainingArgs) > 0 && remainingArgs[0] == "history" && containsArg(remainingArgs, "--global") {
+		runHistory(nil, remainingArgs[1:])
+		return
+	}
+
 	// Load configuration
 	cfg := parser.Config{}
 	info, err := cfg.ReadToml()
@@ -133,18 +165,27 @@ func main() {
 		output.PrintError("Error: %v", err)
 		os.Exit(1)
 	}
+	info.SetShowPrivate(opts.All)
+
+	// "history -i" opens the history view instead of the command picker
+	historyInteractive := interactiveMode && len(remainingArgs) > 0 && remainingArgs[0] == "history"
 
 	// Handle interactive mode
-	if interactiveMode {
-		selected, err := tui.RunPicker(info)
+	if interactiveMode && !historyInteractive {
+		selection, err := tui.RunPicker(info, parallelMode)
 		if err != nil {
 			output.PrintError("Error: %v", err)
 			os.Exit(1)
 		}
-		if selected == "" {
+		if len(selection.Commands) == 0 {
 			return // User cancelled
 		}
-		remainingArgs = []string{selected}
+		remainingArgs = selection.Commands
+		parallelMode = selection.Parallel
+		if selection.Args != nil {
+			opts.Args = selection.Args
+		}
+		opts.Vars = selection.Vars
 	}
 
 	// Handle history replay commands
@@ -152,19 +193,43 @@ func main() {
 	if len(remainingArgs) > 0 {
 		firstArg := remainingArgs[0]
 
-		// "last", "again", or "-" : replay last command
-		if firstArg == "last" || firstArg == "again" || firstArg == "-" {
-			entry, ok := info.GetLastCommand()
+		var entry parser.HistoryEntry
+		var ok bool
+		switch {
+		case firstArg == "again" && len(remainingArgs) > 1:
+			// "again <prefix>" : replay the last command starting with prefix
+			entry, ok = info.FindHistoryByPrefix(remainingArgs[1])
+			if !ok {
+				output.PrintError("No command in history starts with '%s'", remainingArgs[1])
+				os.Exit(1)
+			}
+		case firstArg == "last" || firstArg == "again" || firstArg == "-":
+			// "last", "again", or "-" : replay last command
+			entry, ok = info.GetLastCommand()
 			if !ok {
 				output.PrintError("No command history found")
 				os.Exit(1)
 			}
+		case firstArg == "history" && historyInteractive:
+			// "history -i" : pick an entry to replay
+			entry, ok, err = tui.RunHistory(info)
+			if err != nil {
+				output.PrintError("Error: %v", err)
+				os.Exit(1)
+			}
+			if !ok {
+				return // User cancelled
+			}
+		}
+
+		if ok {
 			output.PrintInfo("Replaying: %s", entry.Command)
 			// Split command string back into separate args (for multi-command history)
 			remainingArgs = strings.Fields(entry.Command)
 			if len(entry.Args) > 0 {
 				opts.Args = entry.Args
 			}
+			opts.Vars = entry.Vars
 		}
 	}
 
@@ -187,26 +252,47 @@ func main() {
 			return // Already handled above
 		} else {
 			// Try interactive mode if available
-			selected, err := tui.RunPicker(info)
+			selection, err := tui.RunPicker(info, parallelMode)
 			if err != nil {
 				// TUI not available, show help
 				printHelp(info)
 				return
 			}
-			if selected == "" {
+			if len(selection.Commands) == 0 {
 				return
 			}
-			command = selected
+			command = selection.Commands[0]
+			remainingArgs = selection.Commands
+			parallelMode = selection.Parallel
+			if selection.Args != nil {
+				opts.Args = selection.Args
+			}
+			opts.Vars = selection.Vars
 		}
 	case "help", "how":
 		printHelp(info)
 		return
 	case "validate":
-		runValidate(info)
+		checkRequires := len(remainingArgs) > 1 && remainingArgs[1] == "--check-requires"
+		runValidate(info, checkRequires)
+		return
+	case "doctor":
+		jsonOutput := len(remainingArgs) > 1 && remainingArgs[1] == "--json"
+		runDoctor(info, jsonOutput)
 		return
 	case "list":
-		// list or list <namespace>
-		if len(remainingArgs) > 1 {
+		// list, list <namespace>, list --tree [namespace] or list --plain (for shell completion)
+		if len(remainingArgs) > 1 && remainingArgs[1] == "--plain" {
+			for _, cmdInfo := range info.GetCommandsInfo() {
+				fmt.Printf("%s\t%s\n", cmdInfo.Name, cmdInfo.Description)
+			}
+		} else if len(remainingArgs) > 1 && remainingArgs[1] == "--tree" {
+			namespace := ""
+			if len(remainingArgs) > 2 {
+				namespace = remainingArgs[2]
+			}
+			info.PrintCommandTree(namespace)
+		} else if len(remainingArgs) > 1 {
 			namespace := remainingArgs[1]
 			commands := info.ListNamespace(namespace)
 			if len(commands) == 0 {
@@ -222,36 +308,42 @@ func main() {
 			info.PrintCommands()
 		}
 		return
-	case "watch":
-		// watch <command> syntax
-		if len(remainingArgs) < 2 {
-			output.PrintError("Usage: imlazy watch <command>")
+	case "history":
+		runHistory(info, remainingArgs[1:])
+		return
+	case "ui":
+		if err := tui.RunDashboard(info, dashboardFlags(opts)); err != nil {
+			output.PrintError("Error: %v", err)
 			os.Exit(1)
 		}
+		return
+	case "watch":
+		// watch <command...> or watch --all syntax
+		remainingArgs = remainingArgs[1:]
+		if len(remainingArgs) == 0 {
+			if !opts.All {
+				output.PrintError("Usage: imlazy watch <command...> | imlazy watch --all")
+				os.Exit(1)
+			}
+			remainingArgs = info.GetWatchedCommands()
+			if len(remainingArgs) == 0 {
+				output.PrintError("No commands define watch patterns")
+				os.Exit(1)
+			}
+		}
 		watchMode = true
-		command = remainingArgs[1]
 	}
 
 	// Watch mode
 	if watchMode {
-		runWatchMode(info, command, opts)
+		if len(remainingArgs) == 0 {
+			remainingArgs = []string{command} // Default command
+		}
+		runWatchMode(info, expandWildcards(info, remainingArgs), opts, debounce, clearScreen)
 		return
 	}
 
-	// Expand wildcard patterns (e.g., test:*)
-	var commands []string
-	for _, arg := range remainingArgs {
-		if strings.Contains(arg, "*") {
-			matches := info.MatchWildcard(arg)
-			if len(matches) == 0 {
-				output.PrintError("No commands matching pattern '%s'", arg)
-				os.Exit(1)
-			}
-			commands = append(commands, matches...)
-		} else {
-			commands = append(commands, arg)
-		}
-	}
+	commands := expandWildcards(info, remainingArgs)
 
 	// Handle multiple commands
 	if len(commands) > 1 {
@@ -263,51 +355,303 @@ func main() {
 			output.PrintInfo("Running %d commands %s: %s", len(commands), mode, strings.Join(commands, ", "))
 		}
 
-		if err := info.RunMultipleCommands(commands, opts, parallelMode); err != nil {
-			// Record failed execution in history
-			info.AddToHistory(parser.HistoryEntry{
-				Command:   strings.Join(commands, " "),
-				Args:      opts.Args,
-				Timestamp: time.Now(),
-				ExitCode:  1,
-			})
+		entry := startHistory(info, strings.Join(commands, " "), &opts)
+		err := info.RunMultipleCommands(commands, opts, parallelMode)
+		finishHistory(info, entry, opts, err)
+		if err != nil {
 			output.PrintError("Error: %v", err)
 			os.Exit(1)
 		}
-
-		// Record successful execution in history
-		info.AddToHistory(parser.HistoryEntry{
-			Command:   strings.Join(commands, " "),
-			Args:      opts.Args,
-			Timestamp: time.Now(),
-			ExitCode:  0,
-		})
 		return
 	}
 
 	// Single command execution
-	if err := info.RunCommandWithOptions(command, opts); err != nil {
-		// Record failed execution in history
-		info.AddToHistory(parser.HistoryEntry{
-			Command:   command,
-			Args:      opts.Args,
-			Timestamp: time.Now(),
-			ExitCode:  1,
-		})
+	entry := startHistory(info, command, &opts)
+	err = info.RunCommandWithOptions(command, opts)
+	finishHistory(info, entry, opts, err)
+	if err != nil {
 		output.PrintError("Error: %v", err)
 		os.Exit(1)
 	}
+}
+
+// startHistory begins the history entry for a run. With settings.history_output
+// set, it also makes opts keep the tail of the run's output, unless that goes to
+// a terminal: copying it would hide the terminal from commands, which then
+// drop colors and progress bars.
+func startHistory(info *parser.Config, command string, opts *parser.RunOptions) parser.HistoryEntry {
+	if lines := info.Settings.HistoryOutput; lines > 0 && !term.IsTerminal(int(os.Stdout.Fd())) {
+		opts.Output = output.NewTailWriter(lines)
+	}
+	return info.NewHistoryEntry(command, *opts)
+}
+
+// finishHistory adds a finished run to the history
+func finishHistory(info *parser.Config, entry parser.HistoryEntry, opts parser.RunOptions, err error) {
+	var lines []string
+	if tail, ok := opts.Output.(*output.TailWriter); ok {
+		lines = tail.Lines()
+	}
+	parser.FinishHistoryEntry(&entry, err, lines)
+	info.AddToHistory(entry)
+}
+
+// runHistory prints recent runs, newest first:
+// history [--limit N] [--failed] [--json] [--global] [search...]
+// info is nil for --global outside a project.
+func runHistory(info *parser.Config, args []string) {
+	// "show" can come after flags, as in history --global show 3
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if arg == "show" {
+			runHistoryShow(info, append(args[:i:i], args[i+1:]...))
+			return
+		}
+		break
+	}
+
+	limit := 20
+	var failedOnly, jsonOutput, global bool
+	var search []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--failed":
+			failedOnly = true
+		case arg == "--global":
+			global = true
+		case arg == "--json":
+			jsonOutput = true
+		case arg == "--limit" || strings.HasPrefix(arg, "--limit="):
+			value := strings.TrimPrefix(arg, "--limit=")
+			if arg == "--limit" {
+				if i+1 >= len(args) {
+					output.PrintError("--limit requires a number")
+					os.Exit(1)
+				}
+				i++
+				value = args[i]
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				output.PrintError("Invalid --limit '%s': expected a number (0 for all)", value)
+				os.Exit(1)
+			}
+			limit = n
+		case strings.HasPrefix(arg, "-"):
+			output.PrintError("Unknown history option '%s'", arg)
+			output.PrintInfo("Usage: imlazy history [--limit N] [--failed] [--json] [--global] [-i] [search...] | imlazy history show [n] [--json] [--global]")
+			os.Exit(1)
+		default:
+			search = append(search, arg)
+		}
+	}
+
+	history := loadHistory(info, global)
+	query := strings.Join(search, " ")
+	entries := parser.FilterHistory(history, parser.HistoryFilter{Limit: limit, FailedOnly: failedOnly, Search: query})
+
+	if jsonOutput {
+		list := make([]historyJSON, len(entries))
+		for i, entry := range entries {
+			list[i] = newHistoryJSON(entry.N, entry.HistoryEntry)
+		}
+		data, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			output.PrintError("Error: %v", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	if len(entries) == 0 {
+		switch {
+		case global && len(history) == 0:
+			output.PrintInfo("No global history found in %s", parser.GlobalHistoryDir())
+			output.PrintInfo("Set IMLAZY_GLOBAL_HISTORY=1 (or global_history = true in [settings]) to record runs across projects")
+		case query != "":
+			output.PrintInfo("No runs in history match '%s'", query)
+		case failedOnly:
+			output.PrintInfo("No failed commands in history")
+		default:
+			output.PrintInfo("No command history found")
+		}
+		return
+	}
+
+	fmt.Println(output.BoldText("%4s  %-12s  %-6s  %8s  %s", "#", "When", "Exit", "Took", "Command"))
+	for _, entry := range entries {
+		status := output.Success("✓ %-4d", entry.ExitCode)
+		if entry.ExitCode != 0 {
+			status = output.Error("✗ %-4d", entry.ExitCode)
+		}
+		took := "-"
+		if entry.Duration > 0 {
+			took = entry.Duration.String()
+		}
+		project := ""
+		if global && entry.Project != "" {
+			project = output.Header("  in %s", tildePath(entry.Project))
+		}
+		fmt.Printf("%4d  %-12s  %s  %8s  %s%s\n", entry.N, entry.Timestamp.Format("Jan 02 15:04"), status, took, output.Command("%s", entry.CommandLine()), project)
+	}
+}
+
+// containsArg reports whether arg is one of args
+func containsArg(args []string, arg string) bool {
+	for _, a := range args {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
+
+// loadHistory reads the project's history, or with global the user-level one
+func loadHistory(info *parser.Config, global bool) []parser.HistoryEntry {
+	var history []parser.HistoryEntry
+	var err error
+	if global {
+		history, err = parser.GetGlobalHistory(0)
+	} else {
+		history, err = info.GetHistory(0)
+	}
+	if err != nil {
+		output.PrintError("Error: %v", err)
+		os.Exit(1)
+	}
+	return history
+}
+
+// tildePath shortens a path in the home directory to start with ~
+func tildePath(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" || home == "/" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if strings.HasPrefix(path, home+string(os.PathSeparator)) {
+		return "~" + path[len(home):]
+	}
+	return path
+}
+
+// historyJSON is a history entry as printed by --json, with the duration as a
+// string like "1.5s" rather than the nanoseconds kept in the history file
+type historyJSON struct {
+	N int `json:"n"`
+	parser.HistoryEntry
+	Duration string `json:"duration,omitempty"`
+}
+
+// newHistoryJSON prepares history entry number n for --json
+func newHistoryJSON(n int, entry parser.HistoryEntry) historyJSON {
+	j := historyJSON{N: n, HistoryEntry: entry}
+	if entry.Duration > 0 {
+		j.Duration = entry.Duration.String()
+	}
+	return j
+}
+
+// runHistoryShow explains a single run: history show [n] [--json] [--global],
+// where n counts from the newest entry as in the history list
+func runHistoryShow(info *parser.Config, args []string) {
+	n := 1
+	var jsonOutput, global bool
+	for _, arg := range args {
+		switch arg {
+		case "--json":
+			jsonOutput = true
+			continue
+		case "--global":
+			global = true
+			continue
+		}
+		value, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+		if err != nil || value < 1 {
+			output.PrintError("Invalid history entry '%s': expected a number from 'imlazy history'", arg)
+			os.Exit(1)
+		}
+		n = value
+	}
+
+	history := loadHistory(info, global)
+	if n > len(history) {
+		output.PrintError("No history entry #%d (%d recorded)", n, len(history))
+		os.Exit(1)
+	}
+	entry := history[len(history)-n]
+
+	if jsonOutput {
+		data, err := json.MarshalIndent(newHistoryJSON(n, entry), "", "  ")
+		if err != nil {
+			output.PrintError("Error: %v", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Printf("%s %s\n", output.BoldText("#%d", n), output.Command("%s", entry.CommandLine()))
 
-	// Record successful execution in history
-	info.AddToHistory(parser.HistoryEntry{
-		Command:   command,
-		Args:      opts.Args,
-		Timestamp: time.Now(),
-		ExitCode:  0,
-	})
+	result := output.Success("✓ passed")
+	if entry.ExitCode != 0 {
+		result = output.Error("✗ failed (exit %d)", entry.ExitCode)
+	}
+	if entry.Duration > 0 {
+		result += fmt.Sprintf(" in %v", entry.Duration)
+	}
+	fmt.Printf("  Result:   %s\n", result)
+	fmt.Printf("  When:     %s\n", entry.Timestamp.Format("Mon Jan 2 2006 15:04:05"))
+	if entry.Project != "" {
+		fmt.Printf("  Project:  %s\n", entry.Project)
+	}
+	if entry.Dir != "" {
+		fmt.Printf("  Dir:      %s\n", entry.Dir)
+	}
+	if entry.GitCommit != "" {
+		commit := entry.GitCommit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		if entry.GitBranch != "" {
+			fmt.Printf("  Git:      %s @ %s\n", entry.GitBranch, commit)
+		} else {
+			fmt.Printf("  Git:      %s (detached)\n", commit)
+		}
+	}
+	if entry.User != "" {
+		fmt.Printf("  User:     %s\n", entry.User)
+	}
+	if len(entry.Vars) > 0 {
+		var vars []string
+		for name, value := range entry.Vars {
+			vars = append(vars, name+"="+value)
+		}
+		sort.Strings(vars)
+		fmt.Printf("  Vars:     %s\n", strings.Join(vars, " "))
+	}
+	if entry.Error != "" {
+		// Errors can span lines, e.g. the command and the exit status
+		fmt.Printf("  Error:    %s\n", strings.ReplaceAll(entry.Error, "\n", "\n            "))
+	}
+	if len(entry.Output) > 0 {
+		fmt.Printf("  Output (last %d lines):\n", len(entry.Output))
+		for _, outLine := range entry.Output {
+			fmt.Printf("    %s\n", outLine)
+		}
+	} else if entry.ExitCode != 0 && info != nil && info.Settings.HistoryOutput == 0 {
+		fmt.Println()
+		output.PrintInfo("Set history_output in [settings] to keep the output of failed runs")
+	}
 }
 
-func runValidate(info *parser.Config) {
+func runValidate(info *parser.Config, checkRequires bool) {
 	output.PrintInfo("Validating %s...", info.ConfigPath())
 	errors := info.Validate()
 	if len(errors) == 0 {
@@ -323,43 +667,300 @@ func runValidate(info *parser.Config) {
 		}
 		os.Exit(1)
 	}
+
+	if checkRequires {
+		fmt.Println()
+		output.PrintInfo("Checking command requirements...")
+		failures := info.CheckAllRequires()
+		if len(failures) > 0 {
+			for _, err := range failures {
+				output.PrintError("%v", err)
+			}
+			os.Exit(1)
+		}
+		output.PrintSuccess("All requirements are met!")
+	}
 }
 
-func runWatchMode(info *parser.Config, command string, opts parser.RunOptions) {
-	// Get watch patterns for the command
-	patterns := info.GetWatchPatterns(command)
-	if len(patterns) == 0 {
-		// Default to watching all Go files if no pattern specified
-		patterns = []string{"**/*.go"}
-		output.PrintWarning("No watch patterns defined for '%s', using default: %v", command, patterns)
+func runDoctor(info *parser.Config, jsonOutput bool) {
+	diag := info.Diagnose()
+
+	if jsonOutput {
+		report := struct {
+			Version string `json:"version"`
+			parser.Diagnostics
+		}{Version, diag}
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			output.PrintError("Error: %v", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		if len(diag.Problems) > 0 {
+			os.Exit(1)
+		}
+		return
 	}
 
-	output.PrintInfo("Watching for changes: %v", patterns)
-	output.PrintInfo("Press Ctrl+C to stop\n")
+	fmt.Println(output.BoldText("ImLazy Doctor"))
+	fmt.Printf("  Version:  %s (%s/%s)\n", Version, runtime.GOOS, runtime.GOARCH)
+	fmt.Println()
 
-	// Run command initially
-	if err := info.RunCommandWithOptions(command, opts); err != nil {
-		output.PrintError("Error: %v", err)
+	fmt.Println(output.BoldText("Config"))
+	fmt.Printf("  File:     %s\n", diag.ConfigPath)
+	for _, include := range diag.Includes {
+		fmt.Printf("  Include:  %s\n", include)
+	}
+	fmt.Printf("  Commands: %d\n", diag.Commands)
+	fmt.Println()
+
+	fmt.Println(output.BoldText("Env files"))
+	if len(diag.EnvFiles) == 0 {
+		fmt.Println(output.Header("  none configured"))
+	}
+	for _, envFile := range diag.EnvFiles {
+		scope := "global"
+		if envFile.Command != "" {
+			scope = envFile.Command
+		}
+		status := output.Success("found")
+		if !envFile.Found {
+			status = output.Warning("missing")
+		}
+		fmt.Printf("  %-24s %-8s %s\n", envFile.Path, status, output.Header("(%s)", scope))
+	}
+	fmt.Println()
+
+	fmt.Println(output.BoldText("Shells"))
+	for _, shell := range diag.Shells {
+		if shell.Path == "" {
+			fmt.Printf("  %-8s %s\n", shell.Name, output.Error("missing (required)"))
+			continue
+		}
+		note := ""
+		if shell.Required {
+			note = output.Header(" (used to run commands)")
+		}
+		fmt.Printf("  %-8s %s%s\n", shell.Name, shell.Path, note)
 	}
+	fmt.Println()
 
-	// Create watcher
-	w, err := watcher.NewWatcher(patterns, 300, func() error {
-		return info.RunCommandWithOptions(command, opts)
-	})
+	fmt.Println(output.BoldText("Watch"))
+	fmt.Printf("  Backend: %s\n", diag.WatchBackend)
+	fmt.Printf("  Directories to watch: %d\n", diag.WatchDirs)
+	if diag.MaxUserWatches >= 0 && diag.WatchBackend != "poll" {
+		limit := fmt.Sprintf("%d", diag.MaxUserWatches)
+		if diag.WatchDirs > diag.MaxUserWatches/2 {
+			limit = output.Warning("%s (close to the limit, consider raising fs.inotify.max_user_watches or setting watch_backend = \"poll\")", limit)
+		}
+		fmt.Printf("  inotify max_user_watches: %s\n", limit)
+	}
+	fmt.Println()
+
+	fmt.Println(output.BoldText("Cache"))
+	fmt.Printf("  Directory: %s\n", diag.CacheDir)
+	if len(diag.CacheFiles) == 0 {
+		fmt.Println(output.Header("  empty"))
+	}
+	for _, file := range diag.CacheFiles {
+		fmt.Printf("  %-24s %s\n", file.Name, formatBytes(file.Size))
+	}
+	fmt.Println()
+
+	printHistoryStatus("History", diag.History)
+	if diag.GlobalHistory != nil {
+		printHistoryStatus("Global History", *diag.GlobalHistory)
+	}
+
+	if len(diag.Problems) > 0 {
+		output.PrintError("Found %d problem(s):", len(diag.Problems))
+		for _, problem := range diag.Problems {
+			fmt.Printf("  - %s\n", problem)
+		}
+		os.Exit(1)
+	}
+	output.PrintSuccess("No problems found!")
+}
+
+// printHistoryStatus prints a history section of the doctor report
+func printHistoryStatus(title string, status parser.HistoryStatus) {
+	fmt.Println(output.BoldText("%s", title))
+	switch {
+	case status.Error != "":
+		fmt.Printf("  %s\n", output.Error("%s", status.Error))
+	case !status.Exists:
+		fmt.Println(output.Header("  no history yet"))
+	default:
+		fmt.Printf("  %d entries in %s (keeps %d)\n", status.Entries, status.Path, status.Size)
+	}
+	fmt.Println()
+}
+
+// formatBytes renders a byte count in human-readable units
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// expandWildcards replaces wildcard patterns (e.g., test:*) with the matching commands
+func expandWildcards(info *parser.Config, args []string) []string {
+	var commands []string
+	for _, arg := range args {
+		if glob.HasMeta(arg) {
+			matches := info.MatchWildcard(arg)
+			if len(matches) == 0 {
+				output.PrintError("No commands matching pattern '%s'", arg)
+				os.Exit(1)
+			}
+			commands = append(commands, matches...)
+		} else {
+			commands = append(commands, arg)
+		}
+	}
+	return commands
+}
+
+// dashboardFlags returns the global flags to pass on to commands run from the dashboard
+func dashboardFlags(opts parser.RunOptions) []string {
+	var flags []string
+	if opts.DryRun {
+		flags = append(flags, "--dry-run")
+	}
+	if opts.Verbose {
+		flags = append(flags, "--verbose")
+	}
+	if opts.Quiet {
+		flags = append(flags, "--quiet")
+	}
+	if opts.Force {
+		flags = append(flags, "--force")
+	}
+	if opts.Yes {
+		flags = append(flags, "--yes")
+	}
+	if opts.All {
+		flags = append(flags, "--all")
+	}
+	return flags
+}
+
+// parseDebounce parses the --debounce flag value
+func parseDebounce(value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		output.PrintError("Invalid --debounce '%s' (expected a duration like 1s or 500ms)", value)
+		os.Exit(1)
+	}
+	return d
+}
+
+// runWatchMode watches files for one or more commands in a single session. Each
+// command only re-runs when its own watch patterns match. A non-zero debounce
+// (from --debounce) overrides watch_debounce from the config, and clearScreen
+// (from --clear) clears the terminal before each re-run.
+func runWatchMode(info *parser.Config, commands []string, opts parser.RunOptions, debounce time.Duration, clearScreen bool) {
+	w, err := watcher.NewWatcher(debounce)
 	if err != nil {
 		output.PrintError("Failed to create watcher: %v", err)
 		os.Exit(1)
 	}
+	w.SetClearScreen(clearScreen)
+	interval, err := info.GetWatchPollInterval()
+	if err != nil {
+		output.PrintError("Error: %v", err)
+		os.Exit(1)
+	}
+	if info.UsePollingWatcher() {
+		w.UsePolling(interval)
+	} else {
+		w.SetPollInterval(interval)
+	}
+
+	// Key presses control the watch when run from a terminal; commands don't get
+	// stdin then, since the keys are read from it
+	interactive := term.IsTerminal(int(os.Stdin.Fd()))
+	opts.NoStdin = interactive
+
+	for _, command := range commands {
+		// Get watch patterns for the command
+		patterns := info.GetWatchPatterns(command)
+		if len(patterns) == 0 {
+			// Default to watching all Go files if no pattern specified
+			patterns = []string{"**/*.go"}
+			output.PrintWarning("No watch patterns defined for '%s', using default: %v", command, patterns)
+		}
+
+		mode := watcher.Mode(info.GetWatchMode(command))
+		cmdOpts := opts
+		if len(commands) > 1 {
+			cmdOpts.Prefix = command
+			output.PrintInfo("Watching for changes: %s %v (mode: %s)", command, patterns, mode)
+		} else {
+			output.PrintInfo("Watching for changes: %v (mode: %s)", patterns, mode)
+		}
+
+		command := command
+		t := w.Add(command, patterns, func(ctx context.Context, changed []string) error {
+			runOpts := cmdOpts
+			runOpts.ChangedFiles = changed
+			runOpts.Affected = info.AffectedCommands(command, changed)
+			return info.RunCommandContext(ctx, command, runOpts)
+		})
+		t.SetMode(mode)
+		if debounce == 0 {
+			cmdDebounce, err := info.GetWatchDebounce(command)
+			if err != nil {
+				output.PrintError("Error: %v", err)
+				os.Exit(1)
+			}
+			t.SetDebounce(cmdDebounce)
+		}
+		t.SetIgnore(info.IgnoreMatcher(command))
+	}
 
 	if err := w.Start(); err != nil {
 		output.PrintError("Failed to start watcher: %v", err)
 		os.Exit(1)
 	}
 
-	// Wait for interrupt signal
+	quit := make(chan struct{})
+	if interactive {
+		stopKeys, err := tui.WatchKeys(func(keys io.Reader) {
+			if w.ReadKeys(keys) == nil {
+				close(quit)
+			}
+		})
+		if err != nil {
+			interactive = false
+			output.PrintWarning("Watch keys unavailable: %v", err)
+		} else {
+			defer stopKeys()
+		}
+	}
+	if interactive {
+		watcher.PrintKeys()
+	} else {
+		output.PrintInfo("Press Ctrl+C to stop\n")
+	}
+
+	// Run commands initially
+	w.TriggerAll()
+
+	// Wait for interrupt signal or q
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	select {
+	case <-sigChan:
+	case <-quit:
+	}
 
 	output.PrintInfo("\nStopping watcher...")
 	w.Stop()
@@ -382,7 +983,11 @@ func printBasicHelp() {
 	fmt.Println("  -q, --quiet        Suppress output except errors")
 	fmt.Println("  -V, --verbose      Show detailed output and timing")
 	fmt.Println("  -f, --force        Force execution (ignore if_changed)")
+	fmt.Println("  -y, --yes          Answer yes to confirmation prompts")
+	fmt.Println("      --all          Include private commands")
 	fmt.Println("  -w, --watch        Watch files and re-run on changes")
+	fmt.Println("      --debounce <d> Wait this long for changes to settle in watch mode")
+	fmt.Println("      --clear        Clear the terminal before each re-run in watch mode")
 	fmt.Println("  -p, --parallel     Run multiple commands in parallel")
 	fmt.Println("  -i, --interactive  Open interactive command picker")
 	fmt.Println("  -v, --version      Show version information")
@@ -393,10 +998,15 @@ func printBasicHelp() {
 	fmt.Println("  help, how          Show available commands")
 	fmt.Println("  version            Show version information")
 	fmt.Println("  validate           Validate lazy.toml configuration")
-	fmt.Println("  list [namespace]   List commands (optionally by namespace)")
-	fmt.Println("  watch <cmd>        Watch files and re-run command on changes")
+	fmt.Println("  doctor [--json]    Diagnose config and environment")
+	fmt.Println("  list [namespace]   List commands (optionally by namespace, --tree for a tree)")
+	fmt.Println("  watch <cmd...>     Watch files and re-run commands on changes")
+	fmt.Println("  ui                 Open a dashboard that runs commands and shows their output")
 	fmt.Println("  completion <shell> Generate shell completion (bash, zsh, fish)")
-	fmt.Println("  last, again, -     Replay last command from history")
+	fmt.Println("  last, again, -     Replay last command from history (again <prefix> for the last match)")
+	fmt.Println("  history            Show recent runs (--limit N, --failed, --json, -i to browse)")
+	fmt.Println("  history --global   Search runs across projects (IMLAZY_GLOBAL_HISTORY=1 to record)")
+	fmt.Println("  history show <n>   Explain a run: where, on which commit, and what it printed")
 	fmt.Println()
 	fmt.Println("No lazy.toml found. Run 'imlazy init' to create one.")
 }
@@ -413,7 +1023,11 @@ func printHelp(info *parser.Config) {
 	fmt.Println("  -q, --quiet        Suppress output except errors")
 	fmt.Println("  -V, --verbose      Show detailed output and timing")
 	fmt.Println("  -f, --force        Force execution (ignore if_changed)")
+	fmt.Println("  -y, --yes          Answer yes to confirmation prompts")
+	fmt.Println("      --all          Include private commands")
 	fmt.Println("  -w, --watch        Watch files and re-run on changes")
+	fmt.Println("      --debounce <d> Wait this long for changes to settle in watch mode")
+	fmt.Println("      --clear        Clear the terminal before each re-run in watch mode")
 	fmt.Println("  -p, --parallel     Run multiple commands in parallel")
 	fmt.Println("  -i, --interactive  Open interactive command picker")
 	fmt.Println("  -v, --version      Show version information")
@@ -434,11 +1048,15 @@ func printHelp(info *parser.Config) {
 		{"init", "Create a new lazy.toml in current directory"},
 		{"help, how", "Show this help message"},
 		{"version", "Show version information"},
-		{"validate", "Validate lazy.toml configuration"},
-		{"list [ns]", "List commands (optionally by namespace)"},
-		{"watch <cmd>", "Watch files and re-run command on changes"},
+		{"validate", "Validate lazy.toml (--check-requires to check requirements)"},
+		{"doctor", "Diagnose config and environment (--json for JSON)"},
+		{"list [ns]", "List commands (optionally by namespace, --tree for a tree)"},
+		{"watch <cmd...>", "Watch and re-run commands on changes (--all for all)"},
+		{"ui", "Open a dashboard that runs commands and shows their output"},
 		{"completion", "Generate shell completion (bash, zsh, fish)"},
-		{"last, again", "Replay last command from history"},
+		{"last, again", "Replay last command from history (again <prefix> for the last match)"},
+		{"history", "Show recent runs (--limit N, --failed, --json, --global, -i to browse)"},
+		{"history show", "Explain a run: where, on which commit, and what it printed"},
 	}
 
 	fmt.Println("Built-in Commands:")
@@ -460,12 +1078,14 @@ func printHelp(info *parser.Config) {
 	fmt.Println("  imlazy -i                Open interactive command picker")
 	fmt.Println("  imlazy last              Replay last command from history")
 	fmt.Println("  imlazy again             Replay last command (alias for last)")
+	fmt.Println("  imlazy again test        Replay the last command starting with 'test'")
+	fmt.Println("  imlazy history --failed  Show recent failed runs")
 	fmt.Println("  imlazy                   Run default or open picker")
 
 	// Show aliases if any exist
 	var aliasExamples []string
 	for name, cmd := range info.Commands {
-		if len(cmd.Alias) > 0 {
+		if len(cmd.Alias) > 0 && !info.IsPrivate(name) {
 			aliasExamples = append(aliasExamples, fmt.Sprintf("'%s' (alias for '%s')", cmd.Alias[0], name))
 		}
 	}
@@ -124,3 +124,11 @@ func PrintCommand(format string, args ...interface{}) {
 func PrintHeader(format string, args ...interface{}) {
 	fmt.Println(Header(format, args...))
 }
+
+// ClearScreen clears the terminal and moves the cursor to the top. Nothing is
+// printed when stdout isn't a terminal, so logs don't fill up with escape codes.
+func ClearScreen() {
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Print("\033[H\033[2J")
+	}
+}
@@ -0,0 +1,63 @@
+package output
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Prefix formats a "[name] " label in magenta, used to tell apart the output of
+// commands running side by side
+func Prefix(name string) string {
+	return colorize(Magenta, "["+name+"] ")
+}
+
+// PrefixWriter writes each line with a prefix. Partial lines are held back until
+// they are completed or Flush is called, so lines from concurrent writers don't
+// get interleaved.
+type PrefixWriter struct {
+	w      io.Writer
+	prefix string
+	mu     sync.Mutex
+	buf    []byte
+}
+
+// NewPrefixWriter creates a writer that prefixes every line written to w
+func NewPrefixWriter(w io.Writer, prefix string) *PrefixWriter {
+	return &PrefixWriter{w: w, prefix: prefix}
+}
+
+// Write implements io.Writer
+func (p *PrefixWriter) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buf = append(p.buf, data...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := append([]byte(p.prefix), p.buf[:i+1]...)
+		if _, err := p.w.Write(line); err != nil {
+			return len(data), err
+		}
+		p.buf = p.buf[i+1:]
+	}
+	return len(data), nil
+}
+
+// Flush writes out any incomplete last line
+func (p *PrefixWriter) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.buf) == 0 {
+		return nil
+	}
+	line := append([]byte(p.prefix), p.buf...)
+	line = append(line, '\n')
+	p.buf = nil
+	_, err := p.w.Write(line)
+	return err
+}
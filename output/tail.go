@@ -0,0 +1,62 @@
+package output
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ansiRe matches terminal escape sequences such as colors
+var ansiRe = regexp.MustCompile(`\x1b\[[0-9;?]*[a-zA-Z]`)
+
+// TailWriter keeps the last lines written to it, without colors, e.g. to show
+// what a failed command printed last
+type TailWriter struct {
+	max     int
+	mu      sync.Mutex
+	lines   []string
+	partial string
+}
+
+// NewTailWriter creates a writer that keeps the last max lines
+func NewTailWriter(max int) *TailWriter {
+	return &TailWriter{max: max}
+}
+
+// Write implements io.Writer
+func (t *TailWriter) Write(data []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	text := t.partial + string(data)
+	parts := strings.Split(text, "\n")
+	t.partial = parts[len(parts)-1]
+	for _, line := range parts[:len(parts)-1] {
+		t.add(line)
+	}
+	return len(data), nil
+}
+
+// add keeps a complete line, dropping the oldest once there are too many
+func (t *TailWriter) add(line string) {
+	line = strings.TrimRight(ansiRe.ReplaceAllString(line, ""), "\r")
+	t.lines = append(t.lines, line)
+	if len(t.lines) > t.max {
+		t.lines = t.lines[len(t.lines)-t.max:]
+	}
+}
+
+// Lines returns the kept lines, oldest first, including an incomplete last line
+func (t *TailWriter) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lines := append([]string(nil), t.lines...)
+	if t.partial != "" {
+		lines = append(lines, strings.TrimRight(ansiRe.ReplaceAllString(t.partial, ""), "\r"))
+		if len(lines) > t.max {
+			lines = lines[1:]
+		}
+	}
+	return lines
+}
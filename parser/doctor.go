@@ -0,0 +1,206 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/javanhut/imlazy/ignore"
+)
+
+// Diagnostics describes the environment a config runs in (for `imlazy doctor`)
+type Diagnostics struct {
+	ConfigPath     string          `json:"config_path"`
+	Includes       []string        `json:"includes"`
+	Commands       int             `json:"commands"`
+	EnvFiles       []EnvFileStatus `json:"env_files"`
+	Shells         []ShellStatus   `json:"shells"`
+	WatchBackend   string          `json:"watch_backend"`    // "fsnotify" or "poll"
+	MaxUserWatches int             `json:"max_user_watches"` // -1 if unknown or not applicable
+	WatchDirs      int             `json:"watch_dirs"`       // Directories a recursive watch would register
+	CacheDir       string          `json:"cache_dir"`
+	CacheFiles     []CacheFile     `json:"cache_files"`
+	History        HistoryStatus   `json:"history"`
+	GlobalHistory  *HistoryStatus  `json:"global_history,omitempty"` // Only if enabled
+	Problems       []string        `json:"problems"`
+}
+
+// EnvFileStatus reports whether a configured dotenv file exists
+type EnvFileStatus struct {
+	Path    string `json:"path"`
+	Command string `json:"command,omitempty"` // Empty for settings.env_file
+	Found   bool   `json:"found"`
+}
+
+// ShellStatus reports whether a shell is available on PATH
+type ShellStatus struct {
+	Name     string `json:"name"`
+	Path     string `json:"path,omitempty"`
+	Required bool   `json:"required"` // Used by imlazy to run commands on this platform
+}
+
+// CacheFile is a file in the .lazy cache directory
+type CacheFile struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// HistoryStatus reports on the health of the history file
+type HistoryStatus struct {
+	Path    string `json:"path"`
+	Exists  bool   `json:"exists"`
+	Entries int    `json:"entries"`
+	Size    int    `json:"size"`    // How many runs are kept
+	Skipped int    `json:"skipped"` // Lines of the log that can't be read
+	Error   string `json:"error,omitempty"`
+}
+
+// Diagnose inspects the loaded config and the machine it runs on
+func (c *Config) Diagnose() Diagnostics {
+	d := Diagnostics{
+		ConfigPath:     c.configPath,
+		Includes:       append([]string{}, c.IncludedFiles()...),
+		Commands:       len(c.Commands),
+		EnvFiles:       []EnvFileStatus{},
+		Shells:         []ShellStatus{},
+		WatchBackend:   "fsnotify",
+		MaxUserWatches: maxUserWatches(),
+		WatchDirs:      countWatchDirs(c.configDir, ignore.New(c.configDir, c.Settings.Ignore)),
+		CacheDir:       filepath.Join(c.configDir, ".lazy"),
+		CacheFiles:     []CacheFile{},
+		Problems:       []string{},
+	}
+
+	d.Problems = append(d.Problems, c.Validate()...)
+	if c.UsePollingWatcher() {
+		d.WatchBackend = "poll"
+	}
+
+	// Env files, global first then by command name
+	for _, file := range c.Settings.EnvFile {
+		d.EnvFiles = append(d.EnvFiles, c.envFileStatus(file, ""))
+	}
+	for _, name := range c.GetCommandNames() {
+		for _, file := range c.Commands[name].EnvFile {
+			d.EnvFiles = append(d.EnvFiles, c.envFileStatus(file, name))
+		}
+	}
+
+	// Shells
+	required := "bash"
+	if runtime.GOOS == "windows" {
+		required = "cmd"
+	}
+	for _, name := range []string{"bash", "sh", "zsh", "fish", "pwsh", "cmd"} {
+		path, err := exec.LookPath(name)
+		if err != nil && name != required {
+			continue
+		}
+		d.Shells = append(d.Shells, ShellStatus{Name: name, Path: path, Required: name == required})
+		if err != nil {
+			d.Problems = append(d.Problems, "required shell '"+name+"' not found in PATH")
+		}
+	}
+
+	// Cache
+	if entries, err := os.ReadDir(d.CacheDir); err == nil {
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || info.IsDir() {
+				continue
+			}
+			d.CacheFiles = append(d.CacheFiles, CacheFile{Name: entry.Name(), Size: info.Size()})
+		}
+	}
+
+	// History
+	d.History = historyStatus(c.projectHistory())
+	if d.History.Error != "" {
+		d.Problems = append(d.Problems, "history: "+d.History.Error)
+	}
+	if c.GlobalHistoryEnabled() {
+		status := HistoryStatus{Error: "no home directory or $XDG_STATE_HOME for the global history"}
+		if global, ok := globalHistory(); ok {
+			status = historyStatus(global)
+		}
+		d.GlobalHistory = &status
+		if status.Error != "" {
+			d.Problems = append(d.Problems, "global history: "+status.Error)
+		}
+	}
+
+	return d
+}
+
+func (c *Config) envFileStatus(file, command string) EnvFileStatus {
+	path := file
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(c.configDir, file)
+	}
+	_, err := os.Stat(path)
+	return EnvFileStatus{Path: file, Command: command, Found: err == nil}
+}
+
+func historyStatus(log historyLog) HistoryStatus {
+	status := HistoryStatus{Path: log.path(), Size: log.size}
+
+	// Until the first run is recorded there may only be the legacy file
+	if _, err := os.Stat(status.Path); os.IsNotExist(err) && log.legacy != "" {
+		status.Path = log.legacy
+	}
+	if _, err := os.Stat(status.Path); err != nil {
+		if !os.IsNotExist(err) {
+			status.Error = err.Error()
+		}
+		return status
+	}
+	status.Exists = true
+
+	history, skipped, err := log.read()
+	if err != nil {
+		status.Error = "corrupt history file: " + err.Error()
+		return status
+	}
+	status.Entries = len(log.trim(history))
+	status.Skipped = skipped
+	if skipped > 0 {
+		status.Error = fmt.Sprintf("%d unreadable line(s) in the history log, dropped when the next run is recorded", skipped)
+	}
+	return status
+}
+
+// maxUserWatches returns the inotify watch limit, or -1 if it can't be read
+func maxUserWatches() int {
+	data, err := os.ReadFile("/proc/sys/fs/inotify/max_user_watches")
+	if err != nil {
+		return -1
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// countWatchDirs counts the directories a recursive watch of root would register
+func countWatchDirs(root string, ignored *ignore.Matcher) int {
+	count := 0
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			// Hidden directories are skipped by the watcher
+			if path != root && (strings.HasPrefix(info.Name(), ".") || ignored.Match(path, true)) {
+				return filepath.SkipDir
+			}
+			count++
+		}
+		return nil
+	})
+	return count
+}
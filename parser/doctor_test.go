@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiagnose(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	mainConfig := `
+[settings]
+include = ["extra.toml"]
+env_file = [".env", ".env.local"]
+
+[commands.build]
+run = ["echo build"]
+env_file = [".env.build"]
+`
+	extraConfig := `
+[commands.test]
+run = ["echo test"]
+`
+	files := map[string]string{
+		"lazy.toml":  mainConfig,
+		"extra.toml": extraConfig,
+		".env":       "A=1\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	cfg := &Config{}
+	result, err := cfg.ReadToml()
+	if err != nil {
+		t.Fatalf("ReadToml error: %v", err)
+	}
+	if err := result.AddToHistory(HistoryEntry{Command: "build"}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := result.Diagnose()
+
+	if len(d.Includes) != 1 || filepath.Base(d.Includes[0]) != "extra.toml" {
+		t.Errorf("Includes = %v, want [extra.toml]", d.Includes)
+	}
+	if d.Commands != 2 {
+		t.Errorf("Commands = %d, want 2", d.Commands)
+	}
+
+	found := map[string]bool{}
+	for _, envFile := range d.EnvFiles {
+		found[envFile.Path] = envFile.Found
+	}
+	if len(found) != 3 || !found[".env"] || found[".env.local"] || found[".env.build"] {
+		t.Errorf("EnvFiles = %+v", d.EnvFiles)
+	}
+
+	if !d.History.Exists || d.History.Entries != 1 {
+		t.Errorf("History = %+v, want 1 entry", d.History)
+	}
+	if len(d.CacheFiles) == 0 {
+		t.Error("expected cache files to be listed")
+	}
+	if len(d.Problems) != 0 {
+		t.Errorf("unexpected problems: %v", d.Problems)
+	}
+
+	// Corrupt history is reported as a problem
+	historyFile := filepath.Join(tmpDir, ".lazy", "history.jsonl")
+	f, err := os.OpenFile(historyFile, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("{not json\n")
+	f.Close()
+	d = result.Diagnose()
+	if d.History.Error == "" || d.History.Skipped != 1 || len(d.Problems) == 0 {
+		t.Error("expected corrupt history to be reported")
+	}
+	if d.History.Entries != 1 {
+		t.Errorf("History.Entries = %d, want the readable entry counted", d.History.Entries)
+	}
+}
@@ -0,0 +1,417 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultHistorySize is how many runs history keeps without settings.history_size
+const defaultHistorySize = 100
+
+// globalHistorySize is how many runs the user-level history keeps, across projects
+const globalHistorySize = 1000
+
+// historyLog is an append-only log of runs in history.jsonl, one JSON entry per
+// line. Writers hold history.lock while appending, so concurrent imlazy
+// processes don't lose each other's runs. Once the log has grown well past its
+// size it is compacted by writing a new file and renaming it into place, so
+// neither readers nor a crash midway see a half-written log.
+type historyLog struct {
+	dir    string // Directory of the log and its lock
+	legacy string // history.json kept before the log, read until the first run is added
+	size   int    // How many runs are kept
+}
+
+// projectHistory returns the history log of the project in .lazy
+func (c *Config) projectHistory() historyLog {
+	size := c.Settings.HistorySize
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	dir := filepath.Join(c.configDir, ".lazy")
+	return historyLog{dir: dir, legacy: filepath.Join(dir, "history.json"), size: size}
+}
+
+// path returns the path of the log
+func (l historyLog) path() string {
+	return filepath.Join(l.dir, "history.jsonl")
+}
+
+// entries returns the most recent runs, oldest first. limit <= 0 returns all
+// that are kept.
+func (l historyLog) entries(limit int) ([]HistoryEntry, error) {
+	history, _, err := l.read()
+	if err != nil {
+		return nil, err
+	}
+
+	// The log can hold more than its size until it is compacted
+	history = l.trim(history)
+	if limit > 0 && len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	if history == nil {
+		history = []HistoryEntry{}
+	}
+	return history, nil
+}
+
+// read reads every entry of the log, falling back to the legacy file if there
+// is no log yet. Lines that can't be parsed, like one cut short by a crash,
+// are skipped and counted.
+func (l historyLog) read() ([]HistoryEntry, int, error) {
+	f, err := os.Open(l.path())
+	if os.IsNotExist(err) {
+		history, err := readLegacyHistory(l.legacy)
+		return history, 0, err
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var history []HistoryEntry
+	skipped := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) // Entries with output can be long
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			skipped++
+			continue
+		}
+		history = append(history, entry)
+	}
+	return history, skipped, scanner.Err()
+}
+
+// readLegacyHistory reads a history.json file, returning nothing if there is
+// none or path is empty
+func readLegacyHistory(path string) ([]HistoryEntry, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var history []HistoryEntry
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// add appends a run to the log, compacting the log to its size once it holds
+// half as many runs again
+func (l historyLog) add(entry HistoryEntry) error {
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return err
+	}
+
+	unlock, err := lockFile(filepath.Join(l.dir, "history.lock"))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// The first run added moves the legacy history over. A legacy file that
+	// can't be read is left alone and history starts afresh.
+	if _, err := os.Stat(l.path()); os.IsNotExist(err) {
+		if legacy, err := readLegacyHistory(l.legacy); err == nil && len(legacy) > 0 {
+			if err := l.write(l.trim(append(legacy, entry))); err != nil {
+				return err
+			}
+			return os.Remove(l.legacy)
+		}
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path(), os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	// Start a new line if a crash left the last one unfinished
+	line = append(line, '\n')
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			line = append([]byte{'\n'}, line...)
+		}
+	}
+	// A single write, so readers see either the whole line or none of it
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	history, skipped, err := l.read()
+	if err != nil {
+		return err
+	}
+	if len(history) > l.size+l.size/2 || skipped > 0 {
+		return l.write(l.trim(history))
+	}
+	return nil
+}
+
+// trim drops the oldest runs beyond the log's size
+func (l historyLog) trim(history []HistoryEntry) []HistoryEntry {
+	if len(history) > l.size {
+		return history[len(history)-l.size:]
+	}
+	return history
+}
+
+// write replaces the log with entries. The caller must hold the lock.
+func (l historyLog) write(entries []HistoryEntry) error {
+	tmp, err := os.CreateTemp(l.dir, "history-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // Fails harmlessly once renamed
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), l.path())
+}
+
+// GetHistory returns recent command history of the project, oldest first.
+// limit <= 0 returns all of it.
+func (c *Config) GetHistory(limit int) ([]HistoryEntry, error) {
+	return c.projectHistory().entries(limit)
+}
+
+// AddToHistory records a run in the project's history and, if enabled, in the
+// user-level history
+func (c *Config) AddToHistory(entry HistoryEntry) error {
+	err := c.projectHistory().add(entry)
+	if c.GlobalHistoryEnabled() {
+		if global, ok := globalHistory(); ok {
+			entry.Project = c.configDir
+			if globalErr := global.add(entry); err == nil {
+				err = globalErr
+			}
+		}
+	}
+	return err
+}
+
+// GlobalHistoryEnabled reports whether runs are also recorded in the
+// user-level history. IMLAZY_GLOBAL_HISTORY overrides settings.global_history.
+func (c *Config) GlobalHistoryEnabled() bool {
+	if value, ok := os.LookupEnv("IMLAZY_GLOBAL_HISTORY"); ok {
+		if enabled, err := strconv.ParseBool(value); err == nil {
+			return enabled
+		}
+	}
+	return c.Settings.GlobalHistory
+}
+
+// GlobalHistoryDir returns where the user-level history is kept:
+// $XDG_STATE_HOME/imlazy, or ~/.local/state/imlazy. It returns "" if neither
+// is known.
+func GlobalHistoryDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "imlazy")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".local", "state", "imlazy")
+}
+
+// globalHistory returns the user-level history log
+func globalHistory() (historyLog, bool) {
+	dir := GlobalHistoryDir()
+	if dir == "" {
+		return historyLog{}, false
+	}
+	return historyLog{dir: dir, size: globalHistorySize}, true
+}
+
+// GetGlobalHistory returns recent runs from the user-level history across
+// projects, oldest first. limit <= 0 returns all of it.
+func GetGlobalHistory(limit int) ([]HistoryEntry, error) {
+	global, ok := globalHistory()
+	if !ok {
+		return []HistoryEntry{}, nil
+	}
+	return global.entries(limit)
+}
+
+// GetLastCommand returns the last executed command from history
+func (c *Config) GetLastCommand() (HistoryEntry, bool) {
+	history, err := c.GetHistory(1)
+	if err != nil || len(history) == 0 {
+		return HistoryEntry{}, false
+	}
+	return history[0], true
+}
+
+// CommandLine is the command of a history entry with its args, as shown in
+// history listings
+func (e HistoryEntry) CommandLine() string {
+	if len(e.Args) == 0 {
+		return e.Command
+	}
+	return e.Command + " -- " + strings.Join(e.Args, " ")
+}
+
+// HistoryFilter selects the runs listed by imlazy history
+type HistoryFilter struct {
+	Limit      int    // Return at most this many entries; 0 for all
+	FailedOnly bool   // Only runs that failed
+	Search     string // Text the command line or project must contain, ignoring case
+}
+
+// NumberedEntry is a history entry with its number, counting from 1 for the
+// newest run. The numbers are what history show takes.
+type NumberedEntry struct {
+	N int `json:"n"`
+	HistoryEntry
+}
+
+// FilterHistory returns the entries of history (oldest first, as returned by
+// GetHistory) that match filter, newest first. Entries keep their number in
+// the full history.
+func FilterHistory(history []HistoryEntry, filter HistoryFilter) []NumberedEntry {
+	search := strings.ToLower(filter.Search)
+	var entries []NumberedEntry
+	for i := len(history) - 1; i >= 0; i-- {
+		if filter.FailedOnly && history[i].ExitCode == 0 {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(history[i].CommandLine()+" "+history[i].Project), search) {
+			continue
+		}
+		entries = append(entries, NumberedEntry{len(history) - i, history[i]})
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+	}
+	return entries
+}
+
+// FindHistoryByPrefix finds the most recent command starting with prefix
+func (c *Config) FindHistoryByPrefix(prefix string) (HistoryEntry, bool) {
+	history, err := c.GetHistory(0)
+	if err != nil {
+		return HistoryEntry{}, false
+	}
+
+	// Search from most recent
+	for i := len(history) - 1; i >= 0; i-- {
+		if strings.HasPrefix(history[i].Command, prefix) {
+			return history[i], true
+		}
+	}
+
+	return HistoryEntry{}, false
+}
+
+// NewHistoryEntry describes a run that is about to start: the command, its
+// args and variable overrides, and where and by whom it is run. The outcome is
+// filled in by FinishHistoryEntry.
+func (c *Config) NewHistoryEntry(command string, opts RunOptions) HistoryEntry {
+	entry := HistoryEntry{
+		Command:   command,
+		Args:      opts.Args,
+		Timestamp: time.Now(),
+		Vars:      opts.Vars,
+		User:      currentUser(),
+	}
+	entry.Dir, _ = os.Getwd()
+	entry.GitBranch, entry.GitCommit = gitHead(c.configDir)
+	return entry
+}
+
+// FinishHistoryEntry records the outcome of a run started at entry.Timestamp.
+// output holds the last lines the run printed and is kept only if it failed.
+// The exit code is the failed process's, or 1 if there is none, e.g. when the
+// run was killed or a requirement wasn't met.
+func FinishHistoryEntry(entry *HistoryEntry, err error, output []string) {
+	entry.Duration = time.Since(entry.Timestamp).Round(time.Millisecond)
+	if err == nil {
+		return
+	}
+	entry.ExitCode = 1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		entry.ExitCode = exitErr.ExitCode()
+	}
+	entry.Error = err.Error()
+	entry.Output = output
+}
+
+// gitHead returns the checked out branch and commit of the repository
+// containing dir, or empty strings if there is none or git isn't installed.
+// The branch is empty on a detached HEAD.
+func gitHead(dir string) (branch, commit string) {
+	cmd := exec.Command("git", "rev-parse", "HEAD", "--abbrev-ref", "HEAD")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", ""
+	}
+	lines := strings.Fields(string(out))
+	if len(lines) != 2 {
+		return "", ""
+	}
+	commit, branch = lines[0], lines[1]
+	if branch == "HEAD" {
+		branch = ""
+	}
+	return branch, commit
+}
+
+// currentUser returns the name of the user running imlazy
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
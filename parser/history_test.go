@@ -0,0 +1,332 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/javanhut/imlazy/output"
+)
+
+func TestMain(m *testing.M) {
+	// Keep runs recorded by tests out of the user-level history
+	os.Setenv("IMLAZY_GLOBAL_HISTORY", "0")
+	os.Exit(m.Run())
+}
+
+func TestHistoryOldFormat(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tmpDir, ".lazy"), 0755)
+
+	// Written before durations and run details were recorded
+	old := `[
+  {
+    "command": "build",
+    "args": ["-v"],
+    "timestamp": "2024-05-01T10:00:00Z",
+    "exit_code": 1
+  }
+]`
+	if err := os.WriteFile(filepath.Join(tmpDir, ".lazy", "history.json"), []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{configDir: tmpDir}
+	entry, ok := cfg.GetLastCommand()
+	if !ok {
+		t.Fatal("old history file did not load")
+	}
+	if entry.Command != "build" || entry.ExitCode != 1 || len(entry.Args) != 1 {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	if entry.Duration != 0 || entry.Dir != "" || entry.Output != nil {
+		t.Errorf("old entry should have no run details, got %+v", entry)
+	}
+
+	// New entries are added next to old ones
+	entry = cfg.NewHistoryEntry("test", RunOptions{Vars: map[string]string{"env": "prod"}})
+	FinishHistoryEntry(&entry, nil, nil)
+	if err := cfg.AddToHistory(entry); err != nil {
+		t.Fatal(err)
+	}
+	history, err := cfg.GetHistory(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[1].Vars["env"] != "prod" {
+		t.Errorf("unexpected history %+v", history)
+	}
+
+	// ... and the old file is moved to the log
+	if _, err := os.Stat(filepath.Join(tmpDir, ".lazy", "history.json")); !os.IsNotExist(err) {
+		t.Error("history.json should be removed once moved to the log")
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, ".lazy", "history.jsonl")); err != nil {
+		t.Errorf("history.jsonl not written: %v", err)
+	}
+}
+
+func TestHistoryConcurrentWriters(t *testing.T) {
+	cfg := &Config{configDir: t.TempDir()}
+
+	const writers = 40
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := cfg.AddToHistory(HistoryEntry{Command: fmt.Sprintf("cmd%d", i)}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	history, skipped, err := cfg.projectHistory().read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != writers || skipped != 0 {
+		t.Errorf("got %d entries and %d unreadable lines, want %d entries", len(history), skipped, writers)
+	}
+}
+
+func TestHistoryCompaction(t *testing.T) {
+	cfg := &Config{Settings: Settings{HistorySize: 10}, configDir: t.TempDir()}
+
+	for i := 1; i <= 40; i++ {
+		if err := cfg.AddToHistory(HistoryEntry{Command: fmt.Sprintf("cmd%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+
+		// The log never grows much past the configured size
+		logged, _, err := cfg.projectHistory().read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(logged) > 15 {
+			t.Fatalf("log holds %d entries after %d runs, want at most 15", len(logged), i)
+		}
+	}
+
+	history, err := cfg.GetHistory(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 10 || history[0].Command != "cmd31" || history[9].Command != "cmd40" {
+		t.Errorf("GetHistory kept %d entries from %q, want cmd31 to cmd40", len(history), history[0].Command)
+	}
+
+	// Unreadable lines, e.g. from a crash mid-write, are skipped and compacted away
+	f, err := os.OpenFile(cfg.projectHistory().path(), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"command": "cut sh`)
+	f.Close()
+	if err := cfg.AddToHistory(HistoryEntry{Command: "after"}); err != nil {
+		t.Fatal(err)
+	}
+	last, ok := cfg.GetLastCommand()
+	if !ok || last.Command != "after" {
+		t.Errorf("GetLastCommand = %q, want 'after'", last.Command)
+	}
+	if _, skipped, _ := cfg.projectHistory().read(); skipped != 0 {
+		t.Errorf("%d unreadable lines left after compaction", skipped)
+	}
+}
+
+func TestGlobalHistory(t *testing.T) {
+	stateDir := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", stateDir)
+
+	app := &Config{Settings: Settings{GlobalHistory: true}, configDir: t.TempDir()}
+	lib := &Config{configDir: t.TempDir()}
+
+	// Disabled by IMLAZY_GLOBAL_HISTORY=0 from TestMain, despite the setting
+	if app.GlobalHistoryEnabled() {
+		t.Error("IMLAZY_GLOBAL_HISTORY=0 should override settings.global_history")
+	}
+	app.AddToHistory(HistoryEntry{Command: "build"})
+	if history, _ := GetGlobalHistory(0); len(history) != 0 {
+		t.Errorf("global history recorded while disabled: %+v", history)
+	}
+
+	// The setting enables it for one project, the environment for all
+	os.Unsetenv("IMLAZY_GLOBAL_HISTORY")
+	defer os.Setenv("IMLAZY_GLOBAL_HISTORY", "0")
+	app.AddToHistory(HistoryEntry{Command: "test"})
+	lib.AddToHistory(HistoryEntry{Command: "lint"})
+	os.Setenv("IMLAZY_GLOBAL_HISTORY", "1")
+	lib.AddToHistory(HistoryEntry{Command: "release"})
+
+	history, err := GetGlobalHistory(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Command != "test" || history[1].Command != "release" {
+		t.Fatalf("global history = %+v, want test and release", history)
+	}
+	if history[0].Project != app.configDir || history[1].Project != lib.configDir {
+		t.Errorf("projects = %q, %q, want the config directories", history[0].Project, history[1].Project)
+	}
+	if GlobalHistoryDir() != filepath.Join(stateDir, "imlazy") {
+		t.Errorf("GlobalHistoryDir() = %q, want it under $XDG_STATE_HOME", GlobalHistoryDir())
+	}
+
+	// Each project keeps its own history for last
+	if last, _ := app.GetLastCommand(); last.Command != "test" || last.Project != "" {
+		t.Errorf("app's last command = %+v, want test without a project", last)
+	}
+	if last, _ := lib.GetLastCommand(); last.Command != "release" {
+		t.Errorf("lib's last command = %q, want release", last.Command)
+	}
+}
+
+func TestFinishHistoryEntry(t *testing.T) {
+	cfg := &Config{configDir: t.TempDir()}
+
+	entry := cfg.NewHistoryEntry("build", RunOptions{Args: []string{"-v"}})
+	if entry.Dir == "" {
+		t.Error("working directory not recorded")
+	}
+	if entry.GitCommit != "" || entry.GitBranch != "" {
+		t.Errorf("expected no git details outside a repository, got %q %q", entry.GitBranch, entry.GitCommit)
+	}
+
+	entry.Timestamp = entry.Timestamp.Add(-1500 * time.Millisecond)
+	output := []string{"compiling", "error: boom"}
+
+	passed := entry
+	FinishHistoryEntry(&passed, nil, output)
+	if passed.ExitCode != 0 || passed.Error != "" || passed.Output != nil {
+		t.Errorf("passed run should keep no error or output, got %+v", passed)
+	}
+	if passed.Duration < 1500*time.Millisecond {
+		t.Errorf("Duration = %v, want at least 1.5s", passed.Duration)
+	}
+
+	failed := entry
+	FinishHistoryEntry(&failed, errors.New("command failed: 'go build'"), output)
+	if failed.ExitCode != 1 || failed.Error != "command failed: 'go build'" {
+		t.Errorf("unexpected failed entry %+v", failed)
+	}
+	if len(failed.Output) != 2 || failed.Output[1] != "error: boom" {
+		t.Errorf("Output = %q, want the given lines", failed.Output)
+	}
+
+	// The exit code of the failed process is kept
+	exited := entry
+	err := exec.Command("sh", "-c", "exit 3").Run()
+	FinishHistoryEntry(&exited, fmt.Errorf("dependency 'gen' failed: %w", err), nil)
+	if exited.ExitCode != 3 {
+		t.Errorf("ExitCode = %d, want 3", exited.ExitCode)
+	}
+}
+
+func TestRunOutputWithBackgroundProcess(t *testing.T) {
+	cfg := &Config{
+		Commands: map[string]Command{
+			"serve": {Run: PlatformRun{Default: []string{"echo started; sleep 10 &"}}},
+		},
+		configDir: t.TempDir(),
+	}
+	cfg.buildAliasMap()
+
+	// The background sleep holds the output pipe open, which must not keep
+	// the run from finishing
+	tail := output.NewTailWriter(5)
+	start := time.Now()
+	if err := cfg.RunCommandWithOptions("serve", RunOptions{Quiet: true, Output: tail}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("run took %v, want it to end soon after the command exits", elapsed)
+	}
+	if lines := tail.Lines(); len(lines) != 1 || lines[0] != "started" {
+		t.Errorf("output = %q, want [started]", lines)
+	}
+}
+
+func TestFilterHistory(t *testing.T) {
+	// Oldest first, as stored
+	history := []HistoryEntry{
+		{Command: "build"},
+		{Command: "test", Args: []string{"-run", "TestParse"}, ExitCode: 1},
+		{Command: "lint"},
+		{Command: "test:unit", ExitCode: 2, Project: "/home/me/api"},
+		{Command: "build"},
+	}
+
+	tests := []struct {
+		name     string
+		filter   HistoryFilter
+		expected []int // Numbers of the entries returned, newest first
+	}{
+		{"all", HistoryFilter{}, []int{1, 2, 3, 4, 5}},
+		{"limit", HistoryFilter{Limit: 2}, []int{1, 2}},
+		{"failed keep their numbers", HistoryFilter{FailedOnly: true}, []int{2, 4}},
+		{"search", HistoryFilter{Search: "TEST"}, []int{2, 4}},
+		{"search args", HistoryFilter{Search: "-- -run testparse"}, []int{4}},
+		{"search project", HistoryFilter{Search: "api"}, []int{2}},
+		{"limit after filtering", HistoryFilter{Search: "build", Limit: 1}, []int{1}},
+		{"no match", HistoryFilter{Search: "deploy"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var numbers []int
+			for _, entry := range FilterHistory(history, tt.filter) {
+				numbers = append(numbers, entry.N)
+				if history[len(history)-entry.N].Command != entry.Command {
+					t.Errorf("entry #%d is %q, want %q", entry.N, entry.Command, history[len(history)-entry.N].Command)
+				}
+			}
+			if !slices.Equal(numbers, tt.expected) {
+				t.Errorf("FilterHistory() numbers = %v, want %v", numbers, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHistoryCommandLine(t *testing.T) {
+	if got := (HistoryEntry{Command: "build test"}).CommandLine(); got != "build test" {
+		t.Errorf("CommandLine() = %q, want %q", got, "build test")
+	}
+	if got := (HistoryEntry{Command: "test", Args: []string{"-v", "./..."}}).CommandLine(); got != "test -- -v ./..." {
+		t.Errorf("CommandLine() = %q, want %q", got, "test -- -v ./...")
+	}
+}
+
+func TestFindHistoryByPrefix(t *testing.T) {
+	cfg := &Config{configDir: t.TempDir()}
+	for _, command := range []string{"test", "test:unit", "build", "test:e2e", "lint"} {
+		if err := cfg.AddToHistory(HistoryEntry{Command: command}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		prefix   string
+		expected string // Empty for no match
+	}{
+		{"test", "test:e2e"}, // The most recent match wins
+		{"test:u", "test:unit"},
+		{"b", "build"},
+		{"", "lint"},
+		{"deploy", ""},
+	}
+
+	for _, tt := range tests {
+		entry, ok := cfg.FindHistoryByPrefix(tt.prefix)
+		if ok != (tt.expected != "") || entry.Command != tt.expected {
+			t.Errorf("FindHistoryByPrefix(%q) = %q, %v, want %q", tt.prefix, entry.Command, ok, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package parser
+
+// lockFile is not supported on this platform, so concurrent writers aren't
+// kept apart
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
@@ -6,7 +6,9 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -20,15 +22,28 @@ import (
 	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/javanhut/imlazy/glob"
+	"github.com/javanhut/imlazy/ignore"
 	"github.com/javanhut/imlazy/output"
+	"golang.org/x/term"
 )
 
 // Settings holds global configuration options
 type Settings struct {
-	Default  string   `toml:"default"`
-	Parallel bool     `toml:"parallel"`
-	Include  []string `toml:"include"`
-	EnvFile  []string `toml:"env_file"` // Dotenv files to load
+	Default           string   `toml:"default"`
+	Parallel          bool     `toml:"parallel"`
+	Include           []string `toml:"include"`
+	EnvFile           []string `toml:"env_file"`            // Dotenv files to load
+	Protected         []string `toml:"protected"`           // Command patterns that require confirmation
+	WatchMode         string   `toml:"watch_mode"`          // Default watch mode: "queue" or "restart"
+	WatchDebounce     string   `toml:"watch_debounce"`      // How long changes must settle before watch mode re-runs (e.g., "1s")
+	WatchBackend      string   `toml:"watch_backend"`       // "fsnotify" (default, falls back to polling) or "poll"
+	WatchPollInterval string   `toml:"watch_poll_interval"` // How often the polling backend scans (e.g., "1s")
+	WatchDeps         bool     `toml:"watch_deps"`          // Watch mode also watches the patterns of dependencies
+	Ignore            []string `toml:"ignore"`              // Gitignore-style patterns skipped by watch and if_changed
+	HistoryOutput     int      `toml:"history_output"`      // Lines of output kept in history for failed runs (0: none)
+	HistorySize       int      `toml:"history_size"`        // How many runs history keeps (default: 100)
+	GlobalHistory     bool     `toml:"global_history"`      // Also record runs in the user-level history
 }
 
 // Config represents the full lazy.toml configuration
@@ -38,54 +53,136 @@ type Config struct {
 	Env       map[string]string  `toml:"env"`
 	Commands  map[string]Command `toml:"commands"`
 	// Internal fields
-	configPath string            // Path to the loaded config file
-	configDir  string            // Directory containing the config file
-	aliasMap   map[string]string // Maps aliases to command names
+	configPath  string            // Path to the loaded config file
+	configDir   string            // Directory containing the config file
+	aliasMap    map[string]string // Maps aliases to command names
+	showPrivate bool              // Include private commands in listings
+	includes    []string          // Paths of included config files, in load order
 }
 
 // HistoryEntry represents a command execution in history
 type HistoryEntry struct {
-	Command   string    `json:"command"`
-	Args      []string  `json:"args"`
-	Timestamp time.Time `json:"timestamp"`
-	ExitCode  int       `json:"exit_code"`
+	Command   string        `json:"command"`
+	Args      []string      `json:"args"`
+	Timestamp time.Time     `json:"timestamp"`
+	ExitCode  int           `json:"exit_code"`
+	Duration  time.Duration `json:"duration,omitempty"` // Zero in entries recorded before durations were kept
+
+	// Where and how the command ran. Older entries don't have these.
+	Dir       string            `json:"dir,omitempty"`        // Working directory imlazy was run from
+	GitBranch string            `json:"git_branch,omitempty"` // Empty outside a repository or on a detached HEAD
+	GitCommit string            `json:"git_commit,omitempty"`
+	User      string            `json:"user,omitempty"`
+	Project   string            `json:"project,omitempty"` // Directory of the project's lazy.toml, in the user-level history
+	Vars      map[string]string `json:"vars,omitempty"`    // Variable overrides
+	Error     string            `json:"error,omitempty"`   // Why the run failed
+	Output    []string          `json:"output,omitempty"`  // Last lines of output of a failed run, see settings.history_output
+}
+
+// Step is a single entry in a run array. Plain strings in `run` become a Step
+// with only Cmd set; the table form allows per-step options.
+type Step struct {
+	Cmd         string // Command line to execute
+	IgnoreError bool   // Continue with the next step if this one fails
+	Timeout     string // Overrides the command timeout for this step
+	Dir         string // Working directory for this step
+	Confirm     string // Prompt shown before running this step
 }
 
 // PlatformRun handles both simple run arrays and platform-specific runs
 type PlatformRun struct {
-	Default []string          // Default run commands (from `run = [...]`)
-	ByOS    map[string][]string // Platform-specific (from `run.linux = [...]`)
+	Default   []string            // Default run commands (from `run = [...]`)
+	ByOS      map[string][]string // Platform-specific (from `run.linux = [...]`)
+	Steps     []Step              // Default steps including per-step options
+	StepsByOS map[string][]Step   // Platform-specific steps including per-step options
 }
 
 // UnmarshalTOML implements custom TOML unmarshaling for PlatformRun
 func (p *PlatformRun) UnmarshalTOML(data interface{}) error {
 	p.ByOS = make(map[string][]string)
+	p.StepsByOS = make(map[string][]Step)
 
 	switch v := data.(type) {
 	case []interface{}:
-		// Simple array: run = ["cmd1", "cmd2"]
-		for _, item := range v {
-			if s, ok := item.(string); ok {
-				p.Default = append(p.Default, s)
-			}
+		// Simple array: run = ["cmd1", {cmd = "cmd2", ignore_error = true}]
+		steps, err := parseSteps(v)
+		if err != nil {
+			return err
 		}
+		p.Steps = steps
+		p.Default = stepCommands(steps)
 	case map[string]interface{}:
 		// Platform-specific: run.linux = [...], run.darwin = [...]
 		for platform, cmds := range v {
 			if arr, ok := cmds.([]interface{}); ok {
-				var commands []string
-				for _, item := range arr {
-					if s, ok := item.(string); ok {
-						commands = append(commands, s)
-					}
+				steps, err := parseSteps(arr)
+				if err != nil {
+					return fmt.Errorf("run.%s: %w", platform, err)
 				}
-				p.ByOS[platform] = commands
+				p.StepsByOS[platform] = steps
+				p.ByOS[platform] = stepCommands(steps)
 			}
 		}
 	}
 	return nil
 }
 
+// parseSteps converts the items of a run array into steps
+func parseSteps(items []interface{}) ([]Step, error) {
+	var steps []Step
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			steps = append(steps, Step{Cmd: v})
+		case map[string]interface{}:
+			step, err := parseStep(v)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+		}
+	}
+	return steps, nil
+}
+
+// parseStep parses the table form of a run step
+func parseStep(table map[string]interface{}) (Step, error) {
+	var step Step
+	for key, value := range table {
+		var ok bool
+		switch key {
+		case "cmd":
+			step.Cmd, ok = value.(string)
+		case "ignore_error":
+			step.IgnoreError, ok = value.(bool)
+		case "timeout":
+			step.Timeout, ok = value.(string)
+		case "dir":
+			step.Dir, ok = value.(string)
+		case "confirm":
+			step.Confirm, ok = value.(string)
+		default:
+			return Step{}, fmt.Errorf("unknown run step option '%s'", key)
+		}
+		if !ok {
+			return Step{}, fmt.Errorf("invalid value for run step option '%s'", key)
+		}
+	}
+	if step.Cmd == "" {
+		return Step{}, fmt.Errorf("run step is missing 'cmd'")
+	}
+	return step, nil
+}
+
+// stepCommands returns the command lines of the given steps
+func stepCommands(steps []Step) []string {
+	cmds := make([]string, len(steps))
+	for i, step := range steps {
+		cmds[i] = step.Cmd
+	}
+	return cmds
+}
+
 // GetForCurrentPlatform returns commands for the current OS, falling back to default
 func (p *PlatformRun) GetForCurrentPlatform() []string {
 	if cmds, ok := p.ByOS[runtime.GOOS]; ok {
@@ -94,23 +191,50 @@ func (p *PlatformRun) GetForCurrentPlatform() []string {
 	return p.Default
 }
 
+// GetStepsForCurrentPlatform returns steps for the current OS, falling back to default.
+// Commands set without step options are returned as plain steps.
+func (p *PlatformRun) GetStepsForCurrentPlatform() []Step {
+	if cmds, ok := p.ByOS[runtime.GOOS]; ok {
+		return withSteps(cmds, p.StepsByOS[runtime.GOOS])
+	}
+	return withSteps(p.Default, p.Steps)
+}
+
+func withSteps(cmds []string, steps []Step) []Step {
+	if len(steps) == len(cmds) {
+		return steps
+	}
+	steps = make([]Step, len(cmds))
+	for i, cmd := range cmds {
+		steps[i] = Step{Cmd: cmd}
+	}
+	return steps
+}
+
 // Command represents a single command definition
 type Command struct {
-	Desc       string            `toml:"desc"`
-	Run        PlatformRun       `toml:"run"`
-	Env        map[string]string `toml:"env"`
-	Dep        []string          `toml:"dep"`
-	Alias      []string          `toml:"alias"`
-	Watch      []string          `toml:"watch"`
-	IfChanged  []string          `toml:"if_changed"`
-	Dir        string            `toml:"dir"`         // Working directory
-	Timeout    string            `toml:"timeout"`     // Timeout duration (e.g., "5m", "30s")
-	Pre        []string          `toml:"pre"`         // Pre-hooks (commands to run before)
-	Post       []string          `toml:"post"`        // Post-hooks (commands to run after, on success)
-	PostAlways bool              `toml:"post_always"` // Run post hooks even on failure
-	Retry      int               `toml:"retry"`       // Number of retry attempts
-	RetryDelay string            `toml:"retry_delay"` // Delay between retries (e.g., "1s")
-	EnvFile    []string          `toml:"env_file"`    // Command-specific dotenv files
+	Desc          string            `toml:"desc"`
+	Run           PlatformRun       `toml:"run"`
+	Env           map[string]string `toml:"env"`
+	Dep           []string          `toml:"dep"`
+	Alias         []string          `toml:"alias"`
+	Watch         []string          `toml:"watch"`
+	IfChanged     []string          `toml:"if_changed"`
+	Dir           string            `toml:"dir"`            // Working directory
+	Timeout       string            `toml:"timeout"`        // Timeout duration (e.g., "5m", "30s")
+	Pre           []string          `toml:"pre"`            // Pre-hooks (commands to run before)
+	Post          []string          `toml:"post"`           // Post-hooks (commands to run after, on success)
+	PostAlways    bool              `toml:"post_always"`    // Run post hooks even on failure
+	Retry         int               `toml:"retry"`          // Number of retry attempts
+	RetryDelay    string            `toml:"retry_delay"`    // Delay between retries (e.g., "1s")
+	EnvFile       []string          `toml:"env_file"`       // Command-specific dotenv files
+	Confirm       string            `toml:"confirm"`        // Prompt shown before running the command
+	Private       bool              `toml:"private"`        // Hide from listings and refuse direct invocation
+	Requires      Requires          `toml:"requires"`       // Tools, env vars and files needed to run
+	WatchMode     string            `toml:"watch_mode"`     // "queue" (default) or "restart" for long-running processes
+	WatchDebounce string            `toml:"watch_debounce"` // Overrides settings.watch_debounce
+	WatchDeps     bool              `toml:"watch_deps"`     // Also watch the patterns of dependencies
+	Ignore        []string          `toml:"ignore"`         // Gitignore-style patterns skipped by watch and if_changed
 }
 
 // RunOptions holds options for running commands
@@ -118,9 +242,23 @@ type RunOptions struct {
 	DryRun       bool
 	Verbose      bool
 	Quiet        bool
-	Force        bool     // Force execution even if files haven't changed
-	Args         []string // Additional arguments to pass through
-	IsDependency bool     // True when running as a dependency of another command
+	Force        bool              // Force execution even if files haven't changed
+	Args         []string          // Additional arguments to pass through
+	IsDependency bool              // True when running as a dependency of another command
+	Yes          bool              // Answer yes to confirmation prompts
+	All          bool              // Allow direct invocation of private commands
+	Prefix       string            // Label for command output lines, e.g. when watching several commands
+	ChangedFiles []string          // Files changed since the last watch run, for {{changed_files}}
+	NoStdin      bool              // Don't connect stdin, e.g. while watch mode reads key presses
+	Affected     map[string]bool   // Dependencies to run in a watch re-run; others are skipped. nil runs all.
+	Vars         map[string]string // Overrides for [variables]
+	Output       io.Writer         // Also receives command output, e.g. to keep its tail for history
+
+	// Set up by the command that runs this one as a dependency or hook, and
+	// passed to processes instead of changing imlazy's own environment and
+	// directory, so commands can run concurrently
+	env map[string]string // Variables from env files, plus env of the parent for post-hooks
+	dir string            // Working directory, "" for the current one
 }
 
 // findConfigFile walks up directories to find lazy.toml
@@ -167,6 +305,20 @@ func (c *Config) buildAliasMap() {
 	}
 }
 
+// unknownKeys returns the keys of a decoded config that no field picked up.
+// Keys inside `run` are skipped: PlatformRun reads step tables itself, and
+// parseStep already rejects options it doesn't know.
+func unknownKeys(md toml.MetaData) []string {
+	var keys []string
+	for _, key := range md.Undecoded() {
+		if len(key) > 3 && key[0] == "commands" && key[2] == "run" {
+			continue
+		}
+		keys = append(keys, key.String())
+	}
+	return keys
+}
+
 // ReadToml reads and parses the lazy.toml configuration file
 func (c *Config) ReadToml() (*Config, error) {
 	configPath, err := findConfigFile()
@@ -199,11 +351,7 @@ func (c *Config) readTomlFromPath(configPath string, visited map[string]bool) (*
 	}
 
 	// Check for undecoded keys (typos in config)
-	if undecoded := md.Undecoded(); len(undecoded) > 0 {
-		keys := make([]string, len(undecoded))
-		for i, key := range undecoded {
-			keys[i] = key.String()
-		}
+	if keys := unknownKeys(md); len(keys) > 0 {
 		output.PrintWarning("Warning: unknown keys in %s: %s", configPath, strings.Join(keys, ", "))
 	}
 
@@ -222,15 +370,14 @@ func (c *Config) readTomlFromPath(configPath string, visited map[string]bool) (*
 
 	// Process includes
 	for _, include := range cfg.Settings.Include {
-		includePath := include
-		if !filepath.IsAbs(includePath) {
-			includePath = filepath.Join(cfg.configDir, include)
+		if err := glob.Validate(include); err != nil {
+			return nil, fmt.Errorf("invalid include pattern '%s': %w", include, err)
 		}
 
-		// Check for glob patterns
-		matches, err := filepath.Glob(includePath)
+		// Include paths may be globs (e.g. "lazy/**/*.toml")
+		matches, err := glob.Files(cfg.configDir, []string{include}, nil)
 		if err != nil {
-			return nil, fmt.Errorf("invalid include pattern '%s': %w", include, err)
+			return nil, fmt.Errorf("failed to resolve include '%s': %w", include, err)
 		}
 
 		for _, match := range matches {
@@ -239,6 +386,8 @@ func (c *Config) readTomlFromPath(configPath string, visited map[string]bool) (*
 			if err != nil {
 				return nil, fmt.Errorf("failed to include '%s': %w", match, err)
 			}
+			cfg.includes = append(cfg.includes, match)
+			cfg.includes = append(cfg.includes, parsedCfg.includes...)
 
 			// Merge included config (included commands don't override existing)
 			for name, cmd := range parsedCfg.Commands {
@@ -309,13 +458,247 @@ func (c *Config) ConfigPath() string {
 	return c.configPath
 }
 
-// GetWatchPatterns returns watch patterns for a command
+// IncludedFiles returns the paths of all config files pulled in via include
+func (c *Config) IncludedFiles() []string {
+	return c.includes
+}
+
+// GetWatchPatterns returns watch patterns for a command. With watch_deps, the
+// patterns of everything it depends on, directly or not, are included.
 func (c *Config) GetWatchPatterns(name string) []string {
 	resolvedName := c.ResolveCommandName(name)
-	if cmd, ok := c.Commands[resolvedName]; ok {
+	cmd, ok := c.Commands[resolvedName]
+	if !ok {
+		return nil
+	}
+	if !c.WatchesDeps(resolvedName) {
 		return cmd.Watch
 	}
-	return nil
+
+	var patterns []string
+	seen := make(map[string]bool)
+	c.walkDeps(resolvedName, make(map[string]bool), func(name string) {
+		for _, pattern := range c.Commands[name].Watch {
+			if !seen[pattern] {
+				seen[pattern] = true
+				patterns = append(patterns, pattern)
+			}
+		}
+	})
+	return patterns
+}
+
+// WatchesDeps reports whether watch_deps is enabled for a command, either on
+// the command itself or in settings
+func (c *Config) WatchesDeps(name string) bool {
+	if cmd, ok := c.Commands[c.ResolveCommandName(name)]; ok && cmd.WatchDeps {
+		return true
+	}
+	return c.Settings.WatchDeps
+}
+
+// walkDeps calls fn for a command and then, depth first, for each command in
+// its dependency graph. Each command is visited once.
+func (c *Config) walkDeps(name string, visited map[string]bool, fn func(name string)) {
+	if visited[name] {
+		return
+	}
+	visited[name] = true
+	cmd, ok := c.Commands[name]
+	if !ok {
+		return
+	}
+	fn(name)
+	for _, dep := range cmd.Dep {
+		c.walkDeps(c.ResolveCommandName(dep), visited, fn)
+	}
+}
+
+// AffectedCommands works out which part of a command's dependency graph a watch
+// re-run needs: the dependencies whose own watch patterns match a changed file
+// (relative to the current directory), and every dependency that depends on
+// one of them. It returns nil, meaning run everything, when watch_deps is off,
+// nothing is known to have changed, or the command's own patterns match.
+func (c *Config) AffectedCommands(name string, changed []string) map[string]bool {
+	resolvedName := c.ResolveCommandName(name)
+	if len(changed) == 0 || !c.WatchesDeps(resolvedName) {
+		return nil
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+
+	matches := func(name string) bool {
+		patterns := glob.Relative(cwd, c.Commands[name].Watch)
+		for _, file := range changed {
+			if glob.MatchAny(patterns, file) {
+				return true
+			}
+		}
+		return false
+	}
+	if matches(resolvedName) {
+		return nil
+	}
+
+	affected := make(map[string]bool)
+	done := make(map[string]bool)
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		if done[name] {
+			return affected[name]
+		}
+		done[name] = true // Also guards against cycles
+		cmd, ok := c.Commands[name]
+		if !ok {
+			return false
+		}
+		hit := matches(name)
+		for _, dep := range cmd.Dep {
+			if visit(c.ResolveCommandName(dep)) {
+				hit = true
+			}
+		}
+		if hit {
+			affected[name] = true
+		}
+		return hit
+	}
+	for _, dep := range c.Commands[resolvedName].Dep {
+		visit(c.ResolveCommandName(dep))
+	}
+	return affected
+}
+
+// depsToRun drops the dependencies a watch re-run found unaffected by the changes
+func (c *Config) depsToRun(deps []string, opts RunOptions) []string {
+	if opts.Affected == nil {
+		return deps
+	}
+	var run []string
+	for _, dep := range deps {
+		if opts.Affected[c.ResolveCommandName(dep)] {
+			run = append(run, dep)
+		} else if !opts.Quiet {
+			output.PrintHeader("Skipping unchanged dependency: %s", dep)
+		}
+	}
+	return run
+}
+
+// GetWatchedCommands returns the commands that have watch patterns, including
+// ones from dependencies when watch_deps is enabled. Private commands are left
+// out even when listed, as they only run as a dependency or hook.
+func (c *Config) GetWatchedCommands() []string {
+	var names []string
+	for name := range c.Commands {
+		if len(c.GetWatchPatterns(name)) > 0 && !c.IsPrivate(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetWatchMode returns the watch mode for a command, falling back to
+// settings.watch_mode and then to "queue"
+func (c *Config) GetWatchMode(name string) string {
+	resolvedName := c.ResolveCommandName(name)
+	if cmd, ok := c.Commands[resolvedName]; ok && cmd.WatchMode != "" {
+		return cmd.WatchMode
+	}
+	if c.Settings.WatchMode != "" {
+		return c.Settings.WatchMode
+	}
+	return "queue"
+}
+
+// GetWatchDebounce returns how long changes must settle before a watched command
+// re-runs, falling back to settings.watch_debounce. It returns 0 if neither is set.
+func (c *Config) GetWatchDebounce(name string) (time.Duration, error) {
+	value := c.Settings.WatchDebounce
+	if cmd, ok := c.Commands[c.ResolveCommandName(name)]; ok && cmd.WatchDebounce != "" {
+		value = cmd.WatchDebounce
+	}
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid watch_debounce '%s': %w", value, err)
+	}
+	return d, nil
+}
+
+// UsePollingWatcher reports whether settings.watch_backend asks for polling
+func (c *Config) UsePollingWatcher() bool {
+	return c.Settings.WatchBackend == "poll"
+}
+
+// GetWatchPollInterval returns settings.watch_poll_interval, or 0 if unset
+func (c *Config) GetWatchPollInterval() (time.Duration, error) {
+	if c.Settings.WatchPollInterval == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(c.Settings.WatchPollInterval)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid watch_poll_interval '%s'", c.Settings.WatchPollInterval)
+	}
+	return d, nil
+}
+
+// IgnoreMatcher returns the ignore rules for a command: settings.ignore and the
+// command's ignore patterns, plus .gitignore and .lazyignore files
+func (c *Config) IgnoreMatcher(name string) *ignore.Matcher {
+	patterns := append([]string{}, c.Settings.Ignore...)
+	if cmd, ok := c.Commands[c.ResolveCommandName(name)]; ok {
+		patterns = append(patterns, cmd.Ignore...)
+	}
+	return ignore.New(c.configDir, patterns)
+}
+
+// variableRe matches {{var_name}} references
+var variableRe = regexp.MustCompile(`\{\{(\w+)\}\}`)
+
+// ReferencedVariables returns the [variables] used by a command's run lines, in
+// order of first use, and whether the run lines take {{args}}
+func (c *Config) ReferencedVariables(name string) ([]string, bool) {
+	cmd, ok := c.Commands[c.ResolveCommandName(name)]
+	if !ok {
+		return nil, false
+	}
+
+	var vars []string
+	seen := make(map[string]bool)
+	usesArgs := false
+	for _, step := range cmd.Run.GetStepsForCurrentPlatform() {
+		for _, match := range variableRe.FindAllStringSubmatch(step.Cmd, -1) {
+			varName := match[1]
+			if varName == "args" {
+				usesArgs = true
+			}
+			if _, ok := c.Variables[varName]; ok && !seen[varName] {
+				seen[varName] = true
+				vars = append(vars, varName)
+			}
+		}
+	}
+	return vars, usesArgs
+}
+
+// ResolveRun returns a command's run lines for the current platform with
+// variables filled in. Values only known at run time, like {{args}}, are left as is.
+func (c *Config) ResolveRun(name string) []string {
+	cmd, ok := c.Commands[c.ResolveCommandName(name)]
+	if !ok {
+		return nil
+	}
+	var lines []string
+	for _, step := range cmd.Run.GetStepsForCurrentPlatform() {
+		lines = append(lines, c.interpolateVariables(step.Cmd, nil))
+	}
+	return lines
 }
 
 // interpolateVariables replaces {{var}} patterns in a string with their values
@@ -327,10 +710,7 @@ func (c *Config) interpolateVariables(input string, extraVars map[string]string)
 		"cwd":  getCwd(),
 	}
 
-	// Pattern to match {{var_name}}
-	re := regexp.MustCompile(`\{\{(\w+)\}\}`)
-
-	return re.ReplaceAllStringFunc(input, func(match string) string {
+	return variableRe.ReplaceAllStringFunc(input, func(match string) string {
 		// Extract variable name (remove {{ and }})
 		varName := match[2 : len(match)-2]
 
@@ -380,7 +760,14 @@ func (c *Config) InitialCommand() {
 # default = "build"  # Uncomment to set default command
 # parallel = false   # Enable parallel dependency execution
 # include = ["ci.toml"]  # Include other config files
+# protected = ["deploy:*"]  # Commands that require confirmation
 # env_file = [".env", ".env.local"]  # Dotenv files to load
+# watch_backend = "poll"  # Poll for changes (NFS, SSHFS, Docker bind mounts)
+# watch_poll_interval = "1s"  # How often to poll (default: 500ms)
+# watch_deps = true  # Watch mode also watches dependencies' patterns
+# history_size = 100  # How many runs to keep in history
+# history_output = 20  # Keep the last 20 lines of output of failed runs in history
+# global_history = true  # Also record runs in ~/.local/state/imlazy to search with history --global
 
 [variables]
 # name = "myproject"
@@ -398,6 +785,10 @@ run = ["echo Hello from imlazy!"]
 # dep = []  # Add dependencies here
 # env = {}  # Add environment variables here
 # watch = ["**/*.go"]  # Watch patterns for watch mode
+# watch_mode = "restart"  # Restart long-running processes on change (default: "queue")
+# watch_debounce = "1s"  # Wait for changes to settle before re-running (default: 300ms)
+# watch_deps = true  # Also watch dependencies' patterns, re-running only what changed
+# ignore = ["dist/", "*.gen.go"]  # Skipped by watch and if_changed (.gitignore is honored too)
 # if_changed = ["src/**/*.go"]  # Only run if these files changed
 # dir = "subdir"  # Working directory for this command
 # timeout = "5m"  # Timeout for command execution
@@ -405,6 +796,9 @@ run = ["echo Hello from imlazy!"]
 # post = ["notify"]  # Commands to run after (on success)
 # retry = 2  # Number of retries on failure
 # retry_delay = "1s"  # Delay between retries
+# confirm = "Are you sure?"  # Ask before running
+# private = true  # Only usable as dep/pre/post (or prefix the name with _)
+# requires = { tools = ["go>=1.21"], env = ["HOME"], files = ["go.mod"] }  # Preflight checks
 
 # Platform-specific commands (use run.linux, run.darwin, run.windows)
 # [commands.build]
@@ -425,10 +819,34 @@ run = ["echo Hello from imlazy!"]
 	output.PrintWarning("lazy.toml already exists in current directory")
 }
 
+// IsPrivate reports whether a command is private, either because it sets
+// private = true or because its name starts with an underscore
+func (c *Config) IsPrivate(name string) bool {
+	resolvedName := c.ResolveCommandName(name)
+	if strings.HasPrefix(resolvedName, "_") {
+		return true
+	}
+	cmd, ok := c.Commands[resolvedName]
+	return ok && cmd.Private
+}
+
+// SetShowPrivate controls whether private commands appear in listings
+func (c *Config) SetShowPrivate(show bool) {
+	c.showPrivate = show
+}
+
+// isListed reports whether a command should appear in listings and suggestions
+func (c *Config) isListed(name string) bool {
+	return c.showPrivate || !c.IsPrivate(name)
+}
+
 // PrintCommands displays all available commands
 func (c *Config) PrintCommands() {
 	fmt.Println("Commands:")
 	for name, cmd := range c.Commands {
+		if !c.isListed(name) {
+			continue
+		}
 		aliasStr := ""
 		if len(cmd.Alias) > 0 {
 			aliasStr = fmt.Sprintf(" (%s)", strings.Join(cmd.Alias, ", "))
@@ -438,6 +856,105 @@ func (c *Config) PrintCommands() {
 	}
 }
 
+// CommandNode is a node of the namespace tree built from command names split on ':'
+type CommandNode struct {
+	Name     string         // Last segment, e.g. "up" for db:migrate:up
+	Path     string         // Full name up to this node, e.g. "db:migrate"
+	Command  bool           // A command with this exact name exists
+	Children []*CommandNode // Sorted by name
+}
+
+// BuildCommandTree groups command names into a tree by their ':' namespace segments
+func BuildCommandTree(names []string) []*CommandNode {
+	root := &CommandNode{}
+	for _, name := range names {
+		node := root
+		for i, segment := range strings.Split(name, ":") {
+			var child *CommandNode
+			for _, existing := range node.Children {
+				if existing.Name == segment {
+					child = existing
+					break
+				}
+			}
+			if child == nil {
+				path := segment
+				if i > 0 {
+					path = node.Path + ":" + segment
+				}
+				child = &CommandNode{Name: segment, Path: path}
+				node.Children = append(node.Children, child)
+			}
+			node = child
+		}
+		node.Command = true
+	}
+
+	var sortNodes func(nodes []*CommandNode)
+	sortNodes = func(nodes []*CommandNode) {
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+		for _, node := range nodes {
+			sortNodes(node.Children)
+		}
+	}
+	sortNodes(root.Children)
+	return root.Children
+}
+
+// PrintCommandTree prints the listed commands grouped by namespace, optionally
+// only the ones in a namespace
+func (c *Config) PrintCommandTree(namespace string) {
+	var names []string
+	if namespace != "" {
+		names = c.ListNamespace(namespace)
+	} else {
+		for name := range c.Commands {
+			if c.isListed(name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	fmt.Println("Commands:")
+	var printNodes func(nodes []*CommandNode, indent string, top bool)
+	printNodes = func(nodes []*CommandNode, indent string, top bool) {
+		for i, node := range nodes {
+			branch, next := "├─ ", "│  "
+			if i == len(nodes)-1 {
+				branch, next = "└─ ", "   "
+			}
+			if top {
+				branch, next = "", ""
+			}
+
+			label := node.Name
+			display := output.Header("%s:", label)
+			desc := ""
+			if node.Command {
+				cmd := c.Commands[node.Path]
+				if len(cmd.Alias) > 0 {
+					label += fmt.Sprintf(" (%s)", strings.Join(cmd.Alias, ", "))
+				}
+				display = output.Command("%s", label)
+				desc = cmd.Desc
+			} else {
+				label += ":"
+			}
+
+			if desc != "" {
+				pad := 20 - len([]rune(indent+branch+label))
+				if pad < 1 {
+					pad = 1
+				}
+				desc = strings.Repeat(" ", pad) + desc
+			}
+			fmt.Printf("  %s%s%s%s\n", indent, branch, display, desc)
+			printNodes(node.Children, indent+next, false)
+		}
+	}
+	printNodes(BuildCommandTree(names), "", true)
+}
+
 // RunCommand executes a command by name with default options
 func (c *Config) RunCommand(name string) error {
 	return c.RunCommandWithOptions(name, RunOptions{})
@@ -445,10 +962,16 @@ func (c *Config) RunCommand(name string) error {
 
 // RunCommandWithOptions executes a command with the specified options
 func (c *Config) RunCommandWithOptions(name string, opts RunOptions) error {
-	return c.runCommandWithVisited(name, make(map[string]bool), opts)
+	return c.RunCommandContext(context.Background(), name, opts)
 }
 
-func (c *Config) runCommandWithVisited(name string, visiting map[string]bool, opts RunOptions) error {
+// RunCommandContext executes a command with the specified options. Cancelling
+// ctx stops the running process tree and skips any remaining steps.
+func (c *Config) RunCommandContext(ctx context.Context, name string, opts RunOptions) error {
+	return c.runCommandWithVisited(ctx, name, make(map[string]bool), opts)
+}
+
+func (c *Config) runCommandWithVisited(ctx context.Context, name string, visiting map[string]bool, opts RunOptions) error {
 	// Resolve aliases
 	resolvedName := c.ResolveCommandName(name)
 	startTime := time.Now()
@@ -464,7 +987,7 @@ func (c *Config) runCommandWithVisited(name string, visiting map[string]bool, op
 			if !opts.Quiet {
 				output.PrintInfo("Fuzzy matched '%s' to '%s'", name, match)
 			}
-			return c.runCommandWithVisited(match, visiting, opts)
+			return c.runCommandWithVisited(ctx, match, visiting, opts)
 		}
 		// Provide helpful suggestions
 		suggestions := c.findSimilarCommands(name)
@@ -474,17 +997,22 @@ func (c *Config) runCommandWithVisited(name string, visiting map[string]bool, op
 		return fmt.Errorf("command not found: '%s'\nRun 'imlazy help' to see available commands", name)
 	}
 
-	// Get platform-specific run commands
-	runCommands := cmd.Run.GetForCurrentPlatform()
-	depCommands := cmd.Dep
+	// Private commands can only run as a dep/pre/post unless --all is passed
+	if c.IsPrivate(resolvedName) && !opts.IsDependency && !opts.All {
+		return fmt.Errorf("'%s' is a private command and can only run as a dependency or hook\nPass --all to run it directly", resolvedName)
+	}
+
+	// Get platform-specific run steps
+	runSteps := cmd.Run.GetStepsForCurrentPlatform()
+	depCommands := c.depsToRun(cmd.Dep, opts)
 
-	if len(runCommands) == 0 {
+	if len(runSteps) == 0 {
 		return fmt.Errorf("no run commands defined for '%s'", resolvedName)
 	}
 
 	// Check if_changed condition (skip when running as a dependency)
 	if len(cmd.IfChanged) > 0 && !opts.Force && !opts.DryRun && !opts.IsDependency {
-		changed, err := c.checkIfChanged(resolvedName, cmd.IfChanged)
+		changed, err := c.checkIfChanged(resolvedName, cmd.IfChanged, c.IgnoreMatcher(resolvedName))
 		if err != nil {
 			if opts.Verbose {
 				output.PrintWarning("Warning: could not check if_changed: %v", err)
@@ -497,16 +1025,50 @@ func (c *Config) runCommandWithVisited(name string, visiting map[string]bool, op
 		}
 	}
 
+	// Prepare extra variables for interpolation
+	extraVars := map[string]string{
+		"args":          strings.Join(opts.Args, " "),
+		"changed_files": changedFilesArg(opts.ChangedFiles, ""),
+	}
+	for key, value := range opts.Vars {
+		extraVars[key] = value
+	}
+
+	// Ask for confirmation on protected commands before anything runs
+	if prompt := c.confirmationPrompt(resolvedName, cmd, extraVars); prompt != "" {
+		if err := confirm(prompt, opts); err != nil {
+			return fmt.Errorf("'%s' %w", resolvedName, err)
+		}
+	}
+
+	// Environment for the command's processes, on top of imlazy's own
+	env := make(map[string]string, len(opts.env))
+	for key, value := range opts.env {
+		env[key] = value
+	}
+
 	// Load global dotenv files
-	if err := c.loadEnvFiles(c.Settings.EnvFile, opts); err != nil {
+	if err := c.loadEnvFiles(c.Settings.EnvFile, env, opts); err != nil {
 		return fmt.Errorf("failed to load global env files: %w", err)
 	}
 
 	// Load command-specific dotenv files
-	if err := c.loadEnvFiles(cmd.EnvFile, opts); err != nil {
+	if err := c.loadEnvFiles(cmd.EnvFile, env, opts); err != nil {
 		return fmt.Errorf("failed to load command env files: %w", err)
 	}
 
+	// Check requirements before any hooks or dependencies run
+	if !cmd.Requires.IsEmpty() {
+		if err := c.checkRequires(resolvedName, cmd, env); err != nil {
+			if !opts.DryRun {
+				return err
+			}
+			if !opts.Quiet {
+				output.PrintWarning("Warning: %v", err)
+			}
+		}
+	}
+
 	// Run pre-hooks before dependencies
 	if len(cmd.Pre) > 0 && !opts.IsDependency {
 		for _, hook := range cmd.Pre {
@@ -516,7 +1078,9 @@ func (c *Config) runCommandWithVisited(name string, visiting map[string]bool, op
 			hookOpts := opts
 			hookOpts.Args = nil
 			hookOpts.IsDependency = true
-			if err := c.runCommandWithVisited(hook, visiting, hookOpts); err != nil {
+			hookOpts.Affected = nil
+			hookOpts.env = env
+			if err := c.runCommandWithVisited(ctx, hook, visiting, hookOpts); err != nil {
 				return fmt.Errorf("pre-hook '%s' failed for command '%s': %w", hook, resolvedName, err)
 			}
 		}
@@ -526,9 +1090,11 @@ func (c *Config) runCommandWithVisited(name string, visiting map[string]bool, op
 	if len(depCommands) > 0 {
 		visiting[resolvedName] = true
 
+		depEnvOpts := opts
+		depEnvOpts.env = env
 		if c.Settings.Parallel {
 			// Parallel dependency execution
-			if err := c.runDepsParallel(depCommands, visiting, opts); err != nil {
+			if err := c.runDepsParallel(ctx, depCommands, visiting, depEnvOpts); err != nil {
 				return err
 			}
 		} else {
@@ -538,10 +1104,10 @@ func (c *Config) runCommandWithVisited(name string, visiting map[string]bool, op
 					output.PrintHeader("Running dependency: %s", dep)
 				}
 				// Don't pass args to dependencies, mark as dependency run
-				depOpts := opts
+				depOpts := depEnvOpts
 				depOpts.Args = nil
 				depOpts.IsDependency = true
-				if err := c.runCommandWithVisited(dep, visiting, depOpts); err != nil {
+				if err := c.runCommandWithVisited(ctx, dep, visiting, depOpts); err != nil {
 					return fmt.Errorf("dependency '%s' failed for command '%s': %w", dep, resolvedName, err)
 				}
 			}
@@ -551,57 +1117,50 @@ func (c *Config) runCommandWithVisited(name string, visiting map[string]bool, op
 
 	// Set global environment variables first
 	for key, value := range c.Env {
-		interpolatedValue := c.interpolateVariables(value, nil)
+		interpolatedValue := c.interpolateVariables(value, opts.Vars)
 		if opts.DryRun {
 			if opts.Verbose && !opts.Quiet {
 				fmt.Printf("[dry-run] export %s=%s (global)\n", key, interpolatedValue)
 			}
 		} else {
-			os.Setenv(key, interpolatedValue)
+			env[key] = interpolatedValue
 		}
 	}
 
 	// Set command-specific environment variables (override global)
 	for key, value := range cmd.Env {
-		interpolatedValue := c.interpolateVariables(value, nil)
+		interpolatedValue := c.interpolateVariables(value, opts.Vars)
 		if opts.DryRun {
 			if !opts.Quiet {
 				fmt.Printf("[dry-run] export %s=%s\n", key, interpolatedValue)
 			}
 		} else {
-			os.Setenv(key, interpolatedValue)
+			env[key] = interpolatedValue
 		}
 	}
 
-	// Prepare extra variables for interpolation
-	extraVars := map[string]string{
-		"args": strings.Join(opts.Args, " "),
-	}
-
 	// Handle working directory
-	var originalDir string
+	dir := opts.dir
 	if cmd.Dir != "" {
-		var err error
-		originalDir, err = os.Getwd()
-		if err != nil {
-			return fmt.Errorf("failed to get current directory: %w", err)
-		}
-		interpolatedDir := c.interpolateVariables(cmd.Dir, extraVars)
+		dir = c.interpolateVariables(cmd.Dir, extraVars)
 		// Make relative paths relative to config file
-		if !filepath.IsAbs(interpolatedDir) {
-			interpolatedDir = filepath.Join(c.configDir, interpolatedDir)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(c.configDir, dir)
 		}
 		if opts.DryRun {
 			if !opts.Quiet {
-				fmt.Printf("[dry-run] cd %s\n", interpolatedDir)
+				fmt.Printf("[dry-run] cd %s\n", dir)
 			}
-		} else {
-			if err := os.Chdir(interpolatedDir); err != nil {
-				return fmt.Errorf("failed to change to directory '%s': %w", interpolatedDir, err)
-			}
-			defer os.Chdir(originalDir)
+		} else if _, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("failed to change to directory '%s': %w", dir, err)
 		}
 	}
+	if dir != "" {
+		extraVars["cwd"] = dir
+		extraVars["changed_files"] = changedFilesArg(opts.ChangedFiles, dir)
+	}
+	opts.env = env
+	opts.dir = dir
 
 	// Parse timeout if specified
 	var timeout time.Duration
@@ -629,8 +1188,10 @@ func (c *Config) runCommandWithVisited(name string, visiting map[string]bool, op
 		maxAttempts = cmd.Retry + 1
 	}
 
-	// Execute commands with retry logic
+	// Execute commands with retry logic. Steps confirmed once aren't asked
+	// about again on a retry.
 	var lastErr error
+	confirmed := make(map[int]bool)
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		if attempt > 1 {
 			if !opts.Quiet {
@@ -641,13 +1202,18 @@ func (c *Config) runCommandWithVisited(name string, visiting map[string]bool, op
 			}
 		}
 
-		err := c.executeCommands(runCommands, extraVars, timeout, opts)
+		err := c.executeCommands(ctx, runSteps, extraVars, timeout, confirmed, opts)
 		if err == nil {
 			lastErr = nil
 			break
 		}
 		lastErr = err
 
+		// Don't retry or run hooks for a cancelled command
+		if ctx.Err() != nil {
+			return err
+		}
+
 		if attempt < maxAttempts {
 			if !opts.Quiet {
 				output.PrintWarning("Command failed, will retry: %v", err)
@@ -665,7 +1231,8 @@ func (c *Config) runCommandWithVisited(name string, visiting map[string]bool, op
 				hookOpts := opts
 				hookOpts.Args = nil
 				hookOpts.IsDependency = true
-				if err := c.runCommandWithVisited(hook, visiting, hookOpts); err != nil {
+				hookOpts.Affected = nil
+				if err := c.runCommandWithVisited(ctx, hook, visiting, hookOpts); err != nil {
 					if !opts.Quiet {
 						output.PrintWarning("post-hook '%s' failed: %v", hook, err)
 					}
@@ -680,7 +1247,7 @@ func (c *Config) runCommandWithVisited(name string, visiting map[string]bool, op
 
 	// Update if_changed cache after successful run
 	if len(cmd.IfChanged) > 0 && !opts.DryRun {
-		c.updateIfChangedCache(resolvedName, cmd.IfChanged)
+		c.updateIfChangedCache(resolvedName, cmd.IfChanged, c.IgnoreMatcher(resolvedName))
 	}
 
 	// Show timing in verbose mode
@@ -692,93 +1259,288 @@ func (c *Config) runCommandWithVisited(name string, visiting map[string]bool, op
 	return nil
 }
 
-// executeCommands runs the command list with optional timeout
-func (c *Config) executeCommands(runCommands []string, extraVars map[string]string, timeout time.Duration, opts RunOptions) error {
-	for _, command := range runCommands {
+// changedFilesArg formats changed files for {{changed_files}}: relative to dir
+// when it is set, and quoted so each path stays one shell argument, whatever
+// characters its name contains
+func changedFilesArg(files []string, dir string) string {
+	cwd := getCwd()
+	quoted := make([]string, len(files))
+	for i, file := range files {
+		if dir != "" {
+			path := file
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(cwd, path)
+			}
+			if rel, err := filepath.Rel(dir, path); err == nil {
+				file = rel
+			}
+		}
+		quoted[i] = shellQuote(filepath.ToSlash(file))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// safeShellWordRe matches words the shell leaves alone without quoting
+var safeShellWordRe = regexp.MustCompile(`^[\w@%+=:,./-]+$`)
+
+// shellQuote quotes s as a single word for bash
+func shellQuote(s string) string {
+	if safeShellWordRe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// executeCommands runs the command steps with optional timeout. Steps whose
+// index is in confirmed aren't asked for confirmation; confirmed steps are
+// added to it. It may be nil to always ask.
+func (c *Config) executeCommands(ctx context.Context, runSteps []Step, extraVars map[string]string, timeout time.Duration, confirmed map[int]bool, opts RunOptions) error {
+	for i, step := range runSteps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		// Interpolate variables in the command
-		interpolatedCmd := c.interpolateVariables(command, extraVars)
+		interpolatedCmd := c.interpolateVariables(step.Cmd, extraVars)
 
 		// Append args if no {{args}} placeholder was used and args were provided
-		if len(opts.Args) > 0 && !strings.Contains(command, "{{args}}") {
+		if len(opts.Args) > 0 && !strings.Contains(step.Cmd, "{{args}}") {
 			interpolatedCmd = interpolatedCmd + " " + strings.Join(opts.Args, " ")
 		}
 
+		// Step-specific working directory (relative to the command's directory)
+		stepDir := opts.dir
+		if step.Dir != "" {
+			stepDir = c.interpolateVariables(step.Dir, extraVars)
+			if !filepath.IsAbs(stepDir) && opts.dir != "" {
+				stepDir = filepath.Join(opts.dir, stepDir)
+			}
+		}
+
+		// Step-specific timeout overrides the command timeout
+		stepTimeout := timeout
+		if step.Timeout != "" {
+			var err error
+			stepTimeout, err = time.ParseDuration(step.Timeout)
+			if err != nil {
+				return fmt.Errorf("invalid timeout '%s' for step '%s': %w", step.Timeout, step.Cmd, err)
+			}
+		}
+
+		// Ask for confirmation before running the step
+		if step.Confirm != "" && !confirmed[i] {
+			if err := confirm(c.interpolateVariables(step.Confirm, extraVars), opts); err != nil {
+				return fmt.Errorf("'%s' %w", interpolatedCmd, err)
+			}
+			if confirmed != nil {
+				confirmed[i] = true
+			}
+		}
+
 		if opts.DryRun {
 			if !opts.Quiet {
-				fmt.Printf("[dry-run] %s\n", interpolatedCmd)
+				if step.Dir != "" {
+					fmt.Printf("[dry-run] (in %s) %s\n", stepDir, interpolatedCmd)
+				} else {
+					fmt.Printf("[dry-run] %s\n", interpolatedCmd)
+				}
 			}
 			continue
 		}
 
 		if !opts.Quiet {
+			if opts.Prefix != "" {
+				fmt.Print(output.Prefix(opts.Prefix))
+			}
 			output.PrintCommand("$ %s", interpolatedCmd)
 		}
 
-		// Create context with timeout if specified
-		var ctx context.Context
-		var cancel context.CancelFunc
-		if timeout > 0 {
-			ctx, cancel = context.WithTimeout(context.Background(), timeout)
-		} else {
-			ctx, cancel = context.WithCancel(context.Background())
+		err := runShellCommand(ctx, interpolatedCmd, stepDir, stepTimeout, opts)
+		if err != nil && step.IgnoreError {
+			if !opts.Quiet {
+				output.PrintWarning("Ignoring error: %v", err)
+			}
+			continue
 		}
-
-		var cmdline *exec.Cmd
-		switch runtime.GOOS {
-		case "linux", "darwin":
-			cmdline = exec.CommandContext(ctx, "bash", "-c", interpolatedCmd)
-		case "windows":
-			cmdline = exec.CommandContext(ctx, "cmd", "/C", interpolatedCmd)
-		default:
-			cmdline = exec.CommandContext(ctx, "bash", "-c", interpolatedCmd)
+		if err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		// Set process group so we can kill child processes on timeout
-		cmdline.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+// killGracePeriod is how long a cancelled process group gets to exit after SIGTERM
+const killGracePeriod = 5 * time.Second
 
-		cmdline.Stdout = os.Stdout
-		cmdline.Stderr = os.Stderr
-		cmdline.Stdin = os.Stdin
+// outputWaitDelay is how long output copied from a command is still read after
+// it exits, e.g. from processes it started in the background
+const outputWaitDelay = time.Second
 
-		// Handle interrupt signals
-		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+// runShellCommand runs a single command line in the platform shell with optional timeout.
+// When parent is cancelled the whole process group is terminated. opts.Prefix
+// labels every line of output and opts.NoStdin leaves stdin unconnected.
+func runShellCommand(parent context.Context, interpolatedCmd, dir string, timeout time.Duration, opts RunOptions) error {
+	// Create context with timeout if specified
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	defer cancel()
 
-		errChan := make(chan error, 1)
-		go func() {
-			errChan <- cmdline.Run()
-		}()
+	var cmdline *exec.Cmd
+	switch runtime.GOOS {
+	case "linux", "darwin":
+		cmdline = exec.Command("bash", "-c", interpolatedCmd)
+	case "windows":
+		cmdline = exec.Command("cmd", "/C", interpolatedCmd)
+	default:
+		cmdline = exec.Command("bash", "-c", interpolatedCmd)
+	}
 
-		select {
-		case err := <-errChan:
-			signal.Stop(sigChan)
-			cancel()
-			if err != nil {
-				return fmt.Errorf("command failed: '%s'\n%w", interpolatedCmd, err)
-			}
-		case <-ctx.Done():
-			// Timeout - kill process group
-			if cmdline.Process != nil {
+	// Set process group so we can kill child processes on timeout
+	cmdline.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	var stdoutW, stderrW io.Writer = os.Stdout, os.Stderr
+	if opts.Output != nil {
+		stdoutW = io.MultiWriter(os.Stdout, opts.Output)
+		stderrW = io.MultiWriter(os.Stderr, opts.Output)
+	}
+
+	// A command that leaves a background process holding its output open would
+	// otherwise keep Wait from returning once output is copied through a writer
+	if opts.Output != nil || opts.Prefix != "" {
+		cmdline.WaitDelay = outputWaitDelay
+	}
+
+	cmdline.Dir = dir
+	if len(opts.env) > 0 {
+		cmdline.Env = os.Environ()
+		for key, value := range opts.env {
+			cmdline.Env = append(cmdline.Env, key+"="+value)
+		}
+	}
+	cmdline.Stdout = stdoutW
+	cmdline.Stderr = stderrW
+	if !opts.NoStdin {
+		cmdline.Stdin = os.Stdin
+	}
+	if opts.Prefix != "" {
+		stdout := output.NewPrefixWriter(stdoutW, output.Prefix(opts.Prefix))
+		stderr := output.NewPrefixWriter(stderrW, output.Prefix(opts.Prefix))
+		defer stdout.Flush()
+		defer stderr.Flush()
+		cmdline.Stdout = stdout
+		cmdline.Stderr = stderr
+	}
+
+	if err := cmdline.Start(); err != nil {
+		return fmt.Errorf("command failed: '%s'\n%w", interpolatedCmd, err)
+	}
+
+	// Handle interrupt signals
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- cmdline.Wait()
+	}()
+
+	select {
+	case err := <-errChan:
+		// Output left open by background processes isn't a failure
+		if err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+			return fmt.Errorf("command failed: '%s'\n%w", interpolatedCmd, err)
+		}
+	case <-ctx.Done():
+		if parent.Err() != nil {
+			// Cancelled by the caller - stop the process group gracefully
+			syscall.Kill(-cmdline.Process.Pid, syscall.SIGTERM)
+			select {
+			case <-errChan:
+			case <-time.After(killGracePeriod):
 				syscall.Kill(-cmdline.Process.Pid, syscall.SIGKILL)
+				<-errChan
 			}
-			signal.Stop(sigChan)
-			cancel()
-			return fmt.Errorf("command timed out after %v: '%s'", timeout, interpolatedCmd)
-		case sig := <-sigChan:
-			// Interrupt - kill process group
-			if cmdline.Process != nil {
-				syscall.Kill(-cmdline.Process.Pid, syscall.SIGTERM)
-			}
-			signal.Stop(sigChan)
-			cancel()
-			return fmt.Errorf("command interrupted by %v: '%s'", sig, interpolatedCmd)
+			return fmt.Errorf("command cancelled: '%s': %w", interpolatedCmd, parent.Err())
+		}
+		// Timeout - kill process group
+		syscall.Kill(-cmdline.Process.Pid, syscall.SIGKILL)
+		<-errChan
+		return fmt.Errorf("command timed out after %v: '%s'", timeout, interpolatedCmd)
+	case sig := <-sigChan:
+		// Interrupt - kill process group
+		syscall.Kill(-cmdline.Process.Pid, syscall.SIGTERM)
+		return fmt.Errorf("command interrupted by %v: '%s'", sig, interpolatedCmd)
+	}
+	return nil
+}
+
+// stdinIsTerminal reports whether confirmation prompts can be answered interactively
+var stdinIsTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// readAnswer reads the answer to a confirmation prompt from stdin
+var readAnswer = func() (string, error) {
+	return bufio.NewReader(os.Stdin).ReadString('\n')
+}
+
+// confirmMu keeps prompts from commands running in parallel from being shown,
+// and read from stdin, at the same time
+var confirmMu sync.Mutex
+
+// confirmationPrompt returns the prompt to show before running a command, or ""
+// if the command neither sets confirm nor matches a settings.protected pattern
+func (c *Config) confirmationPrompt(name string, cmd Command, extraVars map[string]string) string {
+	if cmd.Confirm != "" {
+		return c.interpolateVariables(cmd.Confirm, extraVars)
+	}
+	if glob.MatchAny(c.Settings.Protected, name) {
+		return fmt.Sprintf("Run protected command '%s'?", name)
+	}
+	return ""
+}
+
+// confirm asks the user to confirm an action. It is shown but not asked in dry-run
+// mode, skipped with --yes, and fails closed when stdin is not a terminal.
+func confirm(prompt string, opts RunOptions) error {
+	if opts.DryRun {
+		if !opts.Quiet {
+			fmt.Printf("[dry-run] confirm: %s\n", prompt)
 		}
+		return nil
+	}
+	if opts.Yes {
+		if opts.Verbose && !opts.Quiet {
+			output.PrintInfo("Auto-confirmed: %s", prompt)
+		}
+		return nil
+	}
+	if opts.NoStdin || !stdinIsTerminal() {
+		return fmt.Errorf("requires confirmation (%s); pass --yes to run non-interactively", prompt)
+	}
+
+	confirmMu.Lock()
+	defer confirmMu.Unlock()
+	fmt.Print(output.Warning("%s [y/N] ", prompt))
+	answer, err := readAnswer()
+	if err != nil {
+		return fmt.Errorf("was not confirmed")
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		return fmt.Errorf("was not confirmed")
 	}
 	return nil
 }
 
-// loadEnvFiles loads environment variables from dotenv files
-func (c *Config) loadEnvFiles(files []string, opts RunOptions) error {
+// loadEnvFiles loads environment variables from dotenv files into env
+func (c *Config) loadEnvFiles(files []string, env map[string]string, opts RunOptions) error {
 	for _, file := range files {
 		path := file
 		if !filepath.IsAbs(path) {
@@ -786,7 +1548,10 @@ func (c *Config) loadEnvFiles(files []string, opts RunOptions) error {
 		}
 
 		if _, err := os.Stat(path); os.IsNotExist(err) {
-			// Skip missing env files silently
+			// Missing env files are optional; mention them only in verbose mode
+			if opts.Verbose && !opts.Quiet {
+				output.PrintInfo("Env file not found, skipping: %s", file)
+			}
 			continue
 		}
 
@@ -797,7 +1562,7 @@ func (c *Config) loadEnvFiles(files []string, opts RunOptions) error {
 			continue
 		}
 
-		if err := c.loadDotenv(path); err != nil {
+		if err := c.loadDotenv(path, env); err != nil {
 			return fmt.Errorf("failed to load %s: %w", file, err)
 		}
 
@@ -808,8 +1573,8 @@ func (c *Config) loadEnvFiles(files []string, opts RunOptions) error {
 	return nil
 }
 
-// loadDotenv parses and loads a dotenv file
-func (c *Config) loadDotenv(path string) error {
+// loadDotenv parses a dotenv file into env
+func (c *Config) loadDotenv(path string, env map[string]string) error {
 	file, err := os.Open(path)
 	if err != nil {
 		return err
@@ -845,14 +1610,14 @@ func (c *Config) loadDotenv(path string) error {
 		// Interpolate variables in the value
 		value = c.interpolateVariables(value, nil)
 
-		os.Setenv(key, value)
+		env[key] = value
 	}
 
 	return scanner.Err()
 }
 
 // runDepsParallel runs dependencies in parallel
-func (c *Config) runDepsParallel(deps []string, visiting map[string]bool, opts RunOptions) error {
+func (c *Config) runDepsParallel(ctx context.Context, deps []string, visiting map[string]bool, opts RunOptions) error {
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(deps))
 
@@ -875,7 +1640,7 @@ func (c *Config) runDepsParallel(deps []string, visiting map[string]bool, opts R
 			depOpts := opts
 			depOpts.Args = nil
 			depOpts.IsDependency = true
-			if err := c.runCommandWithVisited(depName, visitingCopy, depOpts); err != nil {
+			if err := c.runCommandWithVisited(ctx, depName, visitingCopy, depOpts); err != nil {
 				errChan <- fmt.Errorf("dependency '%s' failed: %w", depName, err)
 			}
 		}(dep)
@@ -893,7 +1658,7 @@ func (c *Config) runDepsParallel(deps []string, visiting map[string]bool, opts R
 }
 
 // checkIfChanged checks if any files matching the patterns have changed since last run
-func (c *Config) checkIfChanged(cmdName string, patterns []string) (bool, error) {
+func (c *Config) checkIfChanged(cmdName string, patterns []string, ignored *ignore.Matcher) (bool, error) {
 	cacheDir := filepath.Join(c.configDir, ".lazy")
 	cacheFile := filepath.Join(cacheDir, "if_changed.json")
 
@@ -904,7 +1669,7 @@ func (c *Config) checkIfChanged(cmdName string, patterns []string) (bool, error)
 	}
 
 	// Calculate current hash of matching files
-	currentHash, err := c.hashMatchingFiles(patterns)
+	currentHash, err := c.hashMatchingFiles(patterns, ignored)
 	if err != nil {
 		return true, err // If we can't hash, assume changed
 	}
@@ -920,7 +1685,7 @@ func (c *Config) checkIfChanged(cmdName string, patterns []string) (bool, error)
 }
 
 // updateIfChangedCache updates the cache with current file hashes
-func (c *Config) updateIfChangedCache(cmdName string, patterns []string) error {
+func (c *Config) updateIfChangedCache(cmdName string, patterns []string, ignored *ignore.Matcher) error {
 	cacheDir := filepath.Join(c.configDir, ".lazy")
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
 		return err
@@ -935,7 +1700,7 @@ func (c *Config) updateIfChangedCache(cmdName string, patterns []string) error {
 	}
 
 	// Calculate and store current hash
-	currentHash, err := c.hashMatchingFiles(patterns)
+	currentHash, err := c.hashMatchingFiles(patterns, ignored)
 	if err != nil {
 		return err
 	}
@@ -952,71 +1717,28 @@ func (c *Config) updateIfChangedCache(cmdName string, patterns []string) error {
 	return os.WriteFile(cacheFile, data, 0644)
 }
 
-// hashMatchingFiles calculates a hash of all files matching the patterns
-func (c *Config) hashMatchingFiles(patterns []string) (string, error) {
+// hashMatchingFiles calculates a hash of all files matching the patterns,
+// skipping files and directories matched by the ignore rules
+func (c *Config) hashMatchingFiles(patterns []string, ignored *ignore.Matcher) (string, error) {
 	hasher := sha256.New()
 	cwd, _ := os.Getwd()
 
-	for _, pattern := range patterns {
-		// Handle ** glob patterns
-		var matches []string
-		if strings.Contains(pattern, "**") {
-			// Walk directory tree
-			filepath.Walk(cwd, func(path string, info os.FileInfo, err error) error {
-				if err != nil || info.IsDir() {
-					return nil
-				}
-				relPath, _ := filepath.Rel(cwd, path)
-				if matchGlobPattern(pattern, relPath) {
-					matches = append(matches, path)
-				}
-				return nil
-			})
-		} else {
-			var err error
-			matches, err = filepath.Glob(filepath.Join(cwd, pattern))
-			if err != nil {
-				continue
-			}
-		}
-
-		for _, match := range matches {
-			info, err := os.Stat(match)
-			if err != nil {
-				continue
-			}
-			// Include file path and mod time in hash
-			hasher.Write([]byte(match))
-			hasher.Write([]byte(info.ModTime().String()))
-		}
+	matches, err := glob.Files(cwd, patterns, ignored.Match)
+	if err != nil {
+		return "", err
 	}
 
-	return hex.EncodeToString(hasher.Sum(nil)), nil
-}
-
-// matchGlobPattern matches a path against a pattern with ** support
-func matchGlobPattern(pattern, path string) bool {
-	// Convert ** pattern to regex-like matching
-	if strings.Contains(pattern, "**") {
-		parts := strings.Split(pattern, "**")
-		if len(parts) == 2 {
-			prefix := strings.TrimSuffix(parts[0], "/")
-			suffix := strings.TrimPrefix(parts[1], "/")
-
-			if prefix != "" && !strings.HasPrefix(path, prefix) {
-				return false
-			}
-
-			if suffix != "" {
-				matched, _ := filepath.Match(suffix, filepath.Base(path))
-				return matched
-			}
-			return true
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			continue
 		}
+		// Include file path and mod time in hash
+		hasher.Write([]byte(match))
+		hasher.Write([]byte(info.ModTime().String()))
 	}
 
-	matched, _ := filepath.Match(pattern, path)
-	return matched
+	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
 // Validate checks the configuration for errors
@@ -1045,6 +1767,74 @@ func (c *Config) Validate() []string {
 	if c.Settings.Default != "" {
 		if _, ok := c.GetCommand(c.Settings.Default); !ok {
 			errors = append(errors, fmt.Sprintf("default command '%s' is not defined", c.Settings.Default))
+		} else if c.IsPrivate(c.Settings.Default) {
+			errors = append(errors, fmt.Sprintf("default command '%s' is private", c.Settings.Default))
+		}
+	}
+
+	// Check requirement specs are valid
+	for name, cmd := range c.Commands {
+		for _, spec := range cmd.Requires.Tools {
+			if _, err := parseToolRequirement(spec); err != nil {
+				errors = append(errors, fmt.Sprintf("command '%s': %v", name, err))
+			}
+		}
+	}
+
+	// Check watch modes are valid
+	if !isValidWatchMode(c.Settings.WatchMode) {
+		errors = append(errors, fmt.Sprintf("invalid settings.watch_mode '%s' (expected 'queue' or 'restart')", c.Settings.WatchMode))
+	}
+	for name, cmd := range c.Commands {
+		if !isValidWatchMode(cmd.WatchMode) {
+			errors = append(errors, fmt.Sprintf("command '%s' has invalid watch_mode '%s' (expected 'queue' or 'restart')", name, cmd.WatchMode))
+		}
+	}
+
+	// Check watch debounce durations are valid
+	if c.Settings.WatchDebounce != "" {
+		if _, err := time.ParseDuration(c.Settings.WatchDebounce); err != nil {
+			errors = append(errors, fmt.Sprintf("invalid settings.watch_debounce '%s'", c.Settings.WatchDebounce))
+		}
+	}
+	for name, cmd := range c.Commands {
+		if cmd.WatchDebounce == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(cmd.WatchDebounce); err != nil {
+			errors = append(errors, fmt.Sprintf("command '%s' has invalid watch_debounce '%s'", name, cmd.WatchDebounce))
+		}
+	}
+
+	// Check the watch backend settings are valid
+	switch c.Settings.WatchBackend {
+	case "", "fsnotify", "poll":
+	default:
+		errors = append(errors, fmt.Sprintf("invalid settings.watch_backend '%s' (expected 'fsnotify' or 'poll')", c.Settings.WatchBackend))
+	}
+	if _, err := c.GetWatchPollInterval(); err != nil {
+		errors = append(errors, fmt.Sprintf("invalid settings.watch_poll_interval '%s'", c.Settings.WatchPollInterval))
+	}
+	if c.Settings.HistorySize < 0 {
+		errors = append(errors, fmt.Sprintf("invalid settings.history_size %d (expected a number of runs)", c.Settings.HistorySize))
+	}
+	if c.Settings.HistoryOutput < 0 {
+		errors = append(errors, fmt.Sprintf("invalid settings.history_output %d (expected a number of lines)", c.Settings.HistoryOutput))
+	}
+
+	// Check watch and if_changed patterns are valid
+	for name, cmd := range c.Commands {
+		for _, pattern := range append(append([]string{}, cmd.Watch...), cmd.IfChanged...) {
+			if err := glob.Validate(pattern); err != nil {
+				errors = append(errors, fmt.Sprintf("command '%s' has invalid pattern '%s': %v", name, pattern, err))
+			}
+		}
+	}
+
+	// Check protected patterns are valid
+	for _, pattern := range c.Settings.Protected {
+		if err := glob.Validate(pattern); err != nil {
+			errors = append(errors, fmt.Sprintf("invalid protected pattern '%s': %v", pattern, err))
 		}
 	}
 
@@ -1064,6 +1854,10 @@ func (c *Config) Validate() []string {
 	return errors
 }
 
+func isValidWatchMode(mode string) bool {
+	return mode == "" || mode == "queue" || mode == "restart"
+}
+
 func (c *Config) checkCircularDeps(name string, visiting map[string]bool) error {
 	if visiting[name] {
 		return fmt.Errorf("circular dependency detected involving: %s", name)
@@ -1091,6 +1885,9 @@ func (c *Config) findSimilarCommands(name string) []string {
 	nameLower := strings.ToLower(name)
 
 	for cmdName := range c.Commands {
+		if !c.isListed(cmdName) {
+			continue
+		}
 		cmdLower := strings.ToLower(cmdName)
 		// Check if one contains the other or starts with same prefix
 		if strings.Contains(cmdLower, nameLower) ||
@@ -1102,6 +1899,9 @@ func (c *Config) findSimilarCommands(name string) []string {
 
 	// Also check aliases
 	for alias, cmdName := range c.aliasMap {
+		if !c.isListed(cmdName) {
+			continue
+		}
 		aliasLower := strings.ToLower(alias)
 		if strings.Contains(aliasLower, nameLower) || strings.Contains(nameLower, aliasLower) {
 			if !contains(suggestions, cmdName) {
@@ -1136,6 +1936,9 @@ func (c *Config) FuzzyMatch(name string) string {
 	}
 
 	for cmdName := range c.Commands {
+		if !c.isListed(cmdName) {
+			continue
+		}
 		cmdLower := strings.ToLower(cmdName)
 		dist := levenshteinDistance(nameLower, cmdLower)
 
@@ -1152,6 +1955,9 @@ func (c *Config) FuzzyMatch(name string) string {
 
 	// Also check aliases
 	for alias, cmdName := range c.aliasMap {
+		if !c.isListed(cmdName) {
+			continue
+		}
 		aliasLower := strings.ToLower(alias)
 		dist := levenshteinDistance(nameLower, aliasLower)
 
@@ -1224,18 +2030,9 @@ func min(nums ...int) int {
 func (c *Config) MatchWildcard(pattern string) []string {
 	var matches []string
 
-	// Handle namespace wildcard (e.g., "test:*")
-	if strings.HasSuffix(pattern, ":*") {
-		prefix := strings.TrimSuffix(pattern, "*")
+	if glob.HasMeta(pattern) {
 		for name := range c.Commands {
-			if strings.HasPrefix(name, prefix) {
-				matches = append(matches, name)
-			}
-		}
-	} else if strings.Contains(pattern, "*") {
-		// General glob pattern
-		for name := range c.Commands {
-			if matched, _ := filepath.Match(pattern, name); matched {
+			if c.isListed(name) && glob.Match(pattern, name) {
 				matches = append(matches, name)
 			}
 		}
@@ -1255,7 +2052,7 @@ func (c *Config) ListNamespace(namespace string) []string {
 	}
 
 	for name := range c.Commands {
-		if strings.HasPrefix(name, prefix) {
+		if c.isListed(name) && strings.HasPrefix(name, prefix) {
 			matches = append(matches, name)
 		}
 	}
@@ -1264,86 +2061,66 @@ func (c *Config) ListNamespace(namespace string) []string {
 	return matches
 }
 
-// GetHistory returns recent command history
-func (c *Config) GetHistory(limit int) ([]HistoryEntry, error) {
-	historyFile := filepath.Join(c.configDir, ".lazy", "history.json")
-
-	data, err := os.ReadFile(historyFile)
+// GetLastRun returns the most recent history entry for a command (or several
+// commands separated by spaces). Aliases match the commands they stand for,
+// whether they were used in the run or in command.
+func (c *Config) GetLastRun(command string) (HistoryEntry, bool) {
+	history, err := c.GetHistory(0)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return []HistoryEntry{}, nil
-		}
-		return nil, err
-	}
-
-	var history []HistoryEntry
-	if err := json.Unmarshal(data, &history); err != nil {
-		return nil, err
+		return HistoryEntry{}, false
 	}
-
-	if limit > 0 && len(history) > limit {
-		history = history[len(history)-limit:]
+	resolved := c.resolveCommandLine(command)
+	for i := len(history) - 1; i >= 0; i-- {
+		if c.resolveCommandLine(history[i].Command) == resolved {
+			return history[i], true
+		}
 	}
-
-	return history, nil
+	return HistoryEntry{}, false
 }
 
-// AddToHistory adds a command execution to history
-func (c *Config) AddToHistory(entry HistoryEntry) error {
-	cacheDir := filepath.Join(c.configDir, ".lazy")
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		return err
-	}
-
-	historyFile := filepath.Join(cacheDir, "history.json")
-
-	// Load existing history
-	var history []HistoryEntry
-	if data, err := os.ReadFile(historyFile); err == nil {
-		json.Unmarshal(data, &history)
-	}
-
-	// Add new entry
-	history = append(history, entry)
-
-	// Keep only last 100 entries
-	if len(history) > 100 {
-		history = history[len(history)-100:]
-	}
-
-	// Save
-	data, err := json.MarshalIndent(history, "", "  ")
-	if err != nil {
-		return err
+// resolveCommandLine resolves the aliases in a space-separated list of commands
+func (c *Config) resolveCommandLine(commands string) string {
+	names := strings.Fields(commands)
+	for i, name := range names {
+		names[i] = c.ResolveCommandName(name)
 	}
-
-	return os.WriteFile(historyFile, data, 0644)
+	return strings.Join(names, " ")
 }
 
-// GetLastCommand returns the last executed command from history
-func (c *Config) GetLastCommand() (HistoryEntry, bool) {
-	history, err := c.GetHistory(1)
-	if err != nil || len(history) == 0 {
-		return HistoryEntry{}, false
-	}
-	return history[0], true
+// GetLastArgs returns the args of the most recent history entry for a command
+// (or several commands separated by spaces)
+func (c *Config) GetLastArgs(command string) []string {
+	entry, _ := c.GetLastRun(command)
+	return entry.Args
 }
 
-// FindHistoryByPrefix finds the most recent command starting with prefix
-func (c *Config) FindHistoryByPrefix(prefix string) (HistoryEntry, bool) {
-	history, err := c.GetHistory(100)
+// Frecency scores commands by how often and how recently they ran. Each run in
+// history counts more the more recent it is: 4 within the hour, 2 within the
+// day, 1 within the week and 0.5 before that.
+func (c *Config) Frecency() map[string]float64 {
+	scores := make(map[string]float64)
+	history, err := c.GetHistory(0)
 	if err != nil {
-		return HistoryEntry{}, false
+		return scores
 	}
 
-	// Search from most recent
-	for i := len(history) - 1; i >= 0; i-- {
-		if strings.HasPrefix(history[i].Command, prefix) {
-			return history[i], true
+	now := time.Now()
+	for _, entry := range history {
+		weight := 0.5
+		switch age := now.Sub(entry.Timestamp); {
+		case age < time.Hour:
+			weight = 4
+		case age < 24*time.Hour:
+			weight = 2
+		case age < 7*24*time.Hour:
+			weight = 1
+		}
+		// Multi-command runs are recorded as "lint test"
+		for _, name := range strings.Fields(entry.Command) {
+			scores[c.ResolveCommandName(name)] += weight
 		}
 	}
-
-	return HistoryEntry{}, false
+	return scores
 }
 
 // RunMultipleCommands runs multiple commands sequentially or in parallel
@@ -1403,17 +2180,34 @@ type CommandInfo struct {
 	Description string
 	Aliases     []string
 	Run         []string
+	Deps        []string
+	Env         map[string]string
+	Dir         string
+	Timeout     string
+	Retry       int
+	RetryDelay  string
+	Watch       []string
 }
 
 // GetCommandsInfo returns info about all commands (for TUI)
 func (c *Config) GetCommandsInfo() []CommandInfo {
 	var infos []CommandInfo
 	for name, cmd := range c.Commands {
+		if !c.isListed(name) {
+			continue
+		}
 		infos = append(infos, CommandInfo{
 			Name:        name,
 			Description: cmd.Desc,
 			Aliases:     cmd.Alias,
 			Run:         cmd.Run.GetForCurrentPlatform(),
+			Deps:        cmd.Dep,
+			Env:         cmd.Env,
+			Dir:         cmd.Dir,
+			Timeout:     cmd.Timeout,
+			Retry:       cmd.Retry,
+			RetryDelay:  cmd.RetryDelay,
+			Watch:       cmd.Watch,
 		})
 	}
 	// Sort by name
@@ -1,9 +1,16 @@
 package parser
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
 )
 
 func TestInterpolateVariables(t *testing.T) {
@@ -340,32 +347,6 @@ func TestFindSimilarCommands(t *testing.T) {
 	}
 }
 
-func TestMatchGlobPattern(t *testing.T) {
-	tests := []struct {
-		pattern  string
-		path     string
-		expected bool
-	}{
-		{"*.go", "main.go", true},
-		{"*.go", "parser/parser.go", false},
-		{"**/*.go", "main.go", true},
-		{"**/*.go", "parser/parser.go", true},
-		{"**/*.go", "deep/nested/file.go", true},
-		{"**/*.go", "file.txt", false},
-		{"src/**/*.ts", "src/index.ts", true},
-		{"src/**/*.ts", "lib/index.ts", false},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.pattern+"_"+tt.path, func(t *testing.T) {
-			result := matchGlobPattern(tt.pattern, tt.path)
-			if result != tt.expected {
-				t.Errorf("matchGlobPattern(%q, %q) = %v, want %v", tt.pattern, tt.path, result, tt.expected)
-			}
-		})
-	}
-}
-
 func TestReadTomlWithIncludes(t *testing.T) {
 	// Create temp directory
 	tmpDir, err := os.MkdirTemp("", "imlazy-test")
@@ -458,6 +439,31 @@ include = ["lazy.toml"]
 	}
 }
 
+func TestUnknownKeys(t *testing.T) {
+	config := `
+[commands.steps]
+run = [{ cmd = "false", ignore_error = true }, { cmd = "pwd", dir = "sub" }]
+
+[commands.build]
+descr = "Typo"
+run.linux = [{ cmd = "go build", confirm = "Build?" }, "echo done"]
+`
+	var cfg Config
+	md, err := toml.Decode(config, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Step options are read by PlatformRun, only the typo is unknown
+	keys := unknownKeys(md)
+	if !reflect.DeepEqual(keys, []string{"commands.build.descr"}) {
+		t.Errorf("unknownKeys() = %v, want [commands.build.descr]", keys)
+	}
+	if steps := cfg.Commands["steps"].Run.Steps; len(steps) != 2 || !steps[0].IgnoreError || steps[1].Dir != "sub" {
+		t.Errorf("steps = %+v, want step options to be parsed", steps)
+	}
+}
+
 func TestRunOptions(t *testing.T) {
 	opts := RunOptions{
 		DryRun:  true,
@@ -531,6 +537,110 @@ func TestPlatformRunUnmarshal(t *testing.T) {
 	}
 }
 
+func TestPlatformRunUnmarshalSteps(t *testing.T) {
+	p := &PlatformRun{}
+	err := p.UnmarshalTOML([]interface{}{
+		"go build",
+		map[string]interface{}{"cmd": "go vet ./...", "ignore_error": true},
+		map[string]interface{}{"cmd": "go test", "timeout": "2m", "dir": "parser"},
+		map[string]interface{}{"cmd": "./deploy", "confirm": "Really deploy?"},
+	})
+	if err != nil {
+		t.Fatalf("UnmarshalTOML failed: %v", err)
+	}
+
+	if len(p.Default) != 4 || p.Default[1] != "go vet ./..." {
+		t.Errorf("Default = %v, want 4 command lines", p.Default)
+	}
+	if len(p.Steps) != 4 {
+		t.Fatalf("expected 4 steps, got %d", len(p.Steps))
+	}
+	if p.Steps[0].Cmd != "go build" || p.Steps[0].IgnoreError {
+		t.Errorf("plain string step parsed incorrectly: %+v", p.Steps[0])
+	}
+	if !p.Steps[1].IgnoreError {
+		t.Error("expected ignore_error on step 2")
+	}
+	if p.Steps[2].Timeout != "2m" || p.Steps[2].Dir != "parser" {
+		t.Errorf("step 3 options parsed incorrectly: %+v", p.Steps[2])
+	}
+	if p.Steps[3].Confirm != "Really deploy?" {
+		t.Errorf("step 4 confirm = %q", p.Steps[3].Confirm)
+	}
+
+	// Invalid step tables
+	invalid := []map[string]interface{}{
+		{"ignore_error": true},
+		{"cmd": "echo", "unknown": "x"},
+		{"cmd": "echo", "timeout": 5},
+	}
+	for _, table := range invalid {
+		p := &PlatformRun{}
+		if err := p.UnmarshalTOML([]interface{}{table}); err == nil {
+			t.Errorf("expected error for step %v", table)
+		}
+	}
+}
+
+func TestGetStepsForCurrentPlatform(t *testing.T) {
+	// Steps are derived from plain commands when no step options were parsed
+	p := &PlatformRun{Default: []string{"echo a", "echo b"}}
+	steps := p.GetStepsForCurrentPlatform()
+	if len(steps) != 2 || steps[1].Cmd != "echo b" {
+		t.Errorf("GetStepsForCurrentPlatform = %+v", steps)
+	}
+}
+
+func TestExecuteCommandsIgnoreError(t *testing.T) {
+	tmpDir := t.TempDir()
+	marker := filepath.Join(tmpDir, "marker")
+	cfg := &Config{configDir: tmpDir}
+
+	steps := []Step{
+		{Cmd: "exit 1", IgnoreError: true},
+		{Cmd: "touch " + marker},
+	}
+	if err := cfg.executeCommands(context.Background(), steps, nil, 0, nil, RunOptions{Quiet: true}); err != nil {
+		t.Fatalf("executeCommands error: %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("expected step after ignored failure to run")
+	}
+
+	steps = []Step{{Cmd: "exit 1"}, {Cmd: "rm " + marker}}
+	if err := cfg.executeCommands(context.Background(), steps, nil, 0, nil, RunOptions{Quiet: true}); err == nil {
+		t.Error("expected error from failing step")
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("steps after a failure should not run")
+	}
+}
+
+func TestExecuteCommandsStepOptions(t *testing.T) {
+	tmpDir := t.TempDir()
+	subDir := filepath.Join(tmpDir, "sub")
+	if err := os.Mkdir(subDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{configDir: tmpDir}
+
+	// Step dir
+	steps := []Step{{Cmd: "touch here", Dir: subDir}}
+	if err := cfg.executeCommands(context.Background(), steps, nil, 0, nil, RunOptions{Quiet: true}); err != nil {
+		t.Fatalf("executeCommands error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(subDir, "here")); err != nil {
+		t.Error("expected step to run in its dir")
+	}
+
+	// Step timeout overrides command timeout
+	steps = []Step{{Cmd: "sleep 5", Timeout: "50ms"}}
+	err := cfg.executeCommands(context.Background(), steps, nil, 0, nil, RunOptions{Quiet: true})
+	if err == nil || !containsSubstring(err.Error(), "timed out") {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+}
+
 func TestPlatformRunGetForCurrentPlatform(t *testing.T) {
 	p := &PlatformRun{
 		Default: []string{"default-cmd"},
@@ -645,6 +755,8 @@ func TestMatchWildcard(t *testing.T) {
 		{"build:*", []string{"build:dev", "build:prod"}},
 		{"lint:*", []string{}},
 		{"*:unit", []string{"test:unit"}},
+		{"{build,lint}:*", []string{"build:dev", "build:prod"}},
+		{"test:{unit,e2e}", []string{"test:e2e", "test:unit"}},
 	}
 
 	for _, tt := range tests {
@@ -663,6 +775,32 @@ func TestMatchWildcard(t *testing.T) {
 	}
 }
 
+// Test BuildCommandTree
+func TestBuildCommandTree(t *testing.T) {
+	tree := BuildCommandTree([]string{"test:unit", "build", "db:migrate:up", "test", "db:seed", "db:migrate:down"})
+
+	var describe func(nodes []*CommandNode) string
+	describe = func(nodes []*CommandNode) string {
+		var parts []string
+		for _, node := range nodes {
+			part := node.Path
+			if !node.Command {
+				part += "/"
+			}
+			if len(node.Children) > 0 {
+				part += "(" + describe(node.Children) + ")"
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, " ")
+	}
+
+	want := "build db/(db:migrate/(db:migrate:down db:migrate:up) db:seed) test(test:unit)"
+	if got := describe(tree); got != want {
+		t.Errorf("BuildCommandTree() = %s, want %s", got, want)
+	}
+}
+
 // Test ListNamespace
 func TestListNamespace(t *testing.T) {
 	cfg := &Config{
@@ -767,6 +905,85 @@ func TestHistory(t *testing.T) {
 	}
 }
 
+// Test frecency from history
+func TestFrecency(t *testing.T) {
+	cfg := &Config{
+		Commands:  map[string]Command{"build": {Alias: []string{"b"}}, "test": {}, "lint": {}},
+		configDir: t.TempDir(),
+	}
+	cfg.buildAliasMap()
+
+	if scores := cfg.Frecency(); len(scores) != 0 {
+		t.Errorf("Frecency() with no history = %v, want empty", scores)
+	}
+
+	now := time.Now()
+	entries := []HistoryEntry{
+		{Command: "build", Timestamp: now.Add(-30 * 24 * time.Hour)},
+		{Command: "b", Timestamp: now.Add(-2 * 24 * time.Hour)},
+		{Command: "lint test", Timestamp: now.Add(-2 * time.Hour)},
+		{Command: "test", Timestamp: now.Add(-time.Minute)},
+	}
+	for _, e := range entries {
+		if err := cfg.AddToHistory(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]float64{"build": 1.5, "lint": 2, "test": 6}
+	if got := cfg.Frecency(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Frecency() = %v, want %v", got, want)
+	}
+}
+
+// Test variables referenced by run lines and overriding them
+func TestReferencedVariables(t *testing.T) {
+	tmpDir := t.TempDir()
+	out := filepath.Join(tmpDir, "out")
+	cfg := &Config{
+		Variables: map[string]string{"target": "world", "greeting": "hi", "unused": "x"},
+		Commands: map[string]Command{
+			"greet": {Run: PlatformRun{Default: []string{
+				"echo {{greeting}} {{target}} {{args}} > " + out,
+				"echo {{target}} {{os}}",
+			}}},
+			"plain": {Run: PlatformRun{Default: []string{"echo plain"}}},
+		},
+		configDir: tmpDir,
+	}
+	cfg.buildAliasMap()
+
+	vars, usesArgs := cfg.ReferencedVariables("greet")
+	if !reflect.DeepEqual(vars, []string{"greeting", "target"}) || !usesArgs {
+		t.Errorf("ReferencedVariables(greet) = %v, %v", vars, usesArgs)
+	}
+	if vars, usesArgs := cfg.ReferencedVariables("plain"); len(vars) != 0 || usesArgs {
+		t.Errorf("ReferencedVariables(plain) = %v, %v", vars, usesArgs)
+	}
+
+	opts := RunOptions{Quiet: true, Args: []string{"!"}, Vars: map[string]string{"target": "mars"}}
+	if err := cfg.RunCommandWithOptions("greet", opts); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "hi mars !" {
+		t.Errorf("output = %q, want %q", got, "hi mars !")
+	}
+
+	// Args used last time are offered as defaults
+	if args := cfg.GetLastArgs("greet"); args != nil {
+		t.Errorf("GetLastArgs() with no history = %v, want nil", args)
+	}
+	cfg.AddToHistory(HistoryEntry{Command: "greet", Args: []string{"-v"}})
+	cfg.AddToHistory(HistoryEntry{Command: "plain"})
+	if args := cfg.GetLastArgs("greet"); !reflect.DeepEqual(args, []string{"-v"}) {
+		t.Errorf("GetLastArgs(greet) = %v, want [-v]", args)
+	}
+}
+
 // Test GetCommandsInfo
 func TestGetCommandsInfo(t *testing.T) {
 	cfg := &Config{
@@ -809,6 +1026,43 @@ func TestGetCommandsInfo(t *testing.T) {
 	}
 }
 
+// Test run lines resolved for the detail pane and the last run lookup
+func TestResolveRunAndLastRun(t *testing.T) {
+	cfg := &Config{
+		Variables: map[string]string{"out": "bin/app"},
+		Commands: map[string]Command{
+			"build": {Alias: []string{"b"}, Run: PlatformRun{Default: []string{"go build -o {{out}} {{args}}", "echo {{os}}"}}},
+		},
+		configDir: t.TempDir(),
+	}
+	cfg.buildAliasMap()
+
+	want := []string{"go build -o bin/app {{args}}", "echo " + runtime.GOOS}
+	if got := cfg.ResolveRun("b"); !reflect.DeepEqual(got, want) {
+		t.Errorf("ResolveRun(b) = %v, want %v", got, want)
+	}
+
+	if _, ok := cfg.GetLastRun("build"); ok {
+		t.Error("GetLastRun() with no history should return false")
+	}
+	cfg.AddToHistory(HistoryEntry{Command: "build", ExitCode: 1})
+	cfg.AddToHistory(HistoryEntry{Command: "build test", ExitCode: 0})
+	if entry, ok := cfg.GetLastRun("build"); !ok || entry.ExitCode != 1 {
+		t.Errorf("GetLastRun(build) = %+v, %v", entry, ok)
+	}
+
+	// Runs through an alias count for the command, and the other way round
+	cfg.AddToHistory(HistoryEntry{Command: "b", Args: []string{"-v"}})
+	for _, name := range []string{"build", "b"} {
+		if entry, ok := cfg.GetLastRun(name); !ok || len(entry.Args) != 1 {
+			t.Errorf("GetLastRun(%s) = %+v, %v, want the run of b", name, entry, ok)
+		}
+	}
+	if entry, ok := cfg.GetLastRun("b test"); !ok || entry.Command != "build test" {
+		t.Errorf("GetLastRun(b test) = %+v, %v, want the run of build test", entry, ok)
+	}
+}
+
 // Test parsing of new TOML fields
 func TestParseNewConfigFields(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "imlazy-newfields-test")
@@ -961,12 +1215,8 @@ WITH_SPACES = value with spaces
 		configDir: tmpDir,
 	}
 
-	// Clear any existing values
-	os.Unsetenv("DATABASE_URL")
-	os.Unsetenv("API_KEY")
-	os.Unsetenv("DEBUG")
-
-	if err := cfg.loadDotenv(envPath); err != nil {
+	env := map[string]string{}
+	if err := cfg.loadDotenv(envPath, env); err != nil {
 		t.Fatalf("loadDotenv error: %v", err)
 	}
 
@@ -982,9 +1232,602 @@ WITH_SPACES = value with spaces
 
 	for _, tt := range tests {
 		t.Run(tt.key, func(t *testing.T) {
-			if got := os.Getenv(tt.key); got != tt.expected {
-				t.Errorf("os.Getenv(%q) = %q, want %q", tt.key, got, tt.expected)
+			if got, ok := env[tt.key]; !ok || got != tt.expected {
+				t.Errorf("env[%q] = %q, want %q", tt.key, got, tt.expected)
+			}
+		})
+	}
+}
+
+// Test confirmation prompts and protected commands
+func TestConfirmationPrompt(t *testing.T) {
+	cfg := &Config{
+		Settings:  Settings{Protected: []string{"deploy:*", "release"}},
+		Variables: map[string]string{"name": "app"},
+	}
+
+	tests := []struct {
+		name     string
+		cmd      Command
+		expected string
+	}{
+		{"uninstall", Command{Confirm: "Remove {{name}}?"}, "Remove app?"},
+		{"publish", Command{Confirm: "Publish {{args}}?"}, "Publish v1.2?"},
+		{"deploy:prod", Command{}, "Run protected command 'deploy:prod'?"},
+		{"release", Command{}, "Run protected command 'release'?"},
+		{"build", Command{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfg.confirmationPrompt(tt.name, tt.cmd, map[string]string{"args": "v1.2"}); got != tt.expected {
+				t.Errorf("confirmationPrompt(%q) = %q, want %q", tt.name, got, tt.expected)
 			}
 		})
 	}
 }
+
+func TestConfirmNonInteractive(t *testing.T) {
+	oldIsTerminal := stdinIsTerminal
+	stdinIsTerminal = func() bool { return false }
+	defer func() { stdinIsTerminal = oldIsTerminal }()
+
+	// Fails closed without --yes
+	if err := confirm("Sure?", RunOptions{}); err == nil {
+		t.Error("expected confirm to fail in non-interactive session")
+	}
+
+	// --yes and dry-run pass
+	if err := confirm("Sure?", RunOptions{Yes: true}); err != nil {
+		t.Errorf("confirm with Yes returned error: %v", err)
+	}
+	if err := confirm("Sure?", RunOptions{DryRun: true, Quiet: true}); err != nil {
+		t.Errorf("confirm with DryRun returned error: %v", err)
+	}
+}
+
+func TestStepConfirmAskedOnceAcrossRetries(t *testing.T) {
+	oldIsTerminal, oldReadAnswer := stdinIsTerminal, readAnswer
+	defer func() { stdinIsTerminal, readAnswer = oldIsTerminal, oldReadAnswer }()
+	stdinIsTerminal = func() bool { return true }
+	asked := 0
+	readAnswer = func() (string, error) {
+		asked++
+		return "y\n", nil
+	}
+
+	cfg := &Config{
+		Commands: map[string]Command{
+			"flaky": {
+				Run: PlatformRun{
+					Default: []string{"true", "false"},
+					Steps:   []Step{{Cmd: "true", Confirm: "Go?"}, {Cmd: "false"}},
+				},
+				Retry: 2,
+			},
+		},
+	}
+	cfg.buildAliasMap()
+
+	if err := cfg.RunCommandWithOptions("flaky", RunOptions{Quiet: true}); err == nil {
+		t.Fatal("expected flaky to fail")
+	}
+	if asked != 1 {
+		t.Errorf("step confirmation asked %d times over 3 attempts, want 1", asked)
+	}
+}
+
+func TestProtectedCommandRefusesNonInteractive(t *testing.T) {
+	oldIsTerminal := stdinIsTerminal
+	stdinIsTerminal = func() bool { return false }
+	defer func() { stdinIsTerminal = oldIsTerminal }()
+
+	tmpDir := t.TempDir()
+	marker := filepath.Join(tmpDir, "marker")
+	cfg := &Config{
+		Settings: Settings{Protected: []string{"deploy:*"}},
+		Commands: map[string]Command{
+			"deploy:prod": {Run: PlatformRun{Default: []string{"touch " + marker}}},
+		},
+		configDir: tmpDir,
+	}
+	cfg.buildAliasMap()
+
+	if err := cfg.RunCommandWithOptions("deploy:prod", RunOptions{Quiet: true}); err == nil {
+		t.Error("expected protected command to be refused")
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("protected command should not have run")
+	}
+
+	if err := cfg.RunCommandWithOptions("deploy:prod", RunOptions{Quiet: true, Yes: true}); err != nil {
+		t.Errorf("expected --yes to allow protected command, got %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("protected command should have run with --yes")
+	}
+}
+
+// Test private commands
+func TestPrivateCommands(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &Config{
+		Commands: map[string]Command{
+			"build":     {Dep: []string{"_gen"}, Run: PlatformRun{Default: []string{"true"}}, Watch: []string{"*.go"}},
+			"_gen":      {Run: PlatformRun{Default: []string{"true"}}, Watch: []string{"*.proto"}},
+			"test:unit": {Run: PlatformRun{Default: []string{"true"}}},
+			"test:util": {Private: true, Alias: []string{"tu"}, Run: PlatformRun{Default: []string{"true"}}},
+		},
+		configDir: tmpDir,
+	}
+	cfg.buildAliasMap()
+
+	if !cfg.IsPrivate("_gen") || !cfg.IsPrivate("test:util") || !cfg.IsPrivate("tu") {
+		t.Error("expected _gen, test:util and its alias to be private")
+	}
+	if cfg.IsPrivate("build") {
+		t.Error("build should not be private")
+	}
+
+	// Hidden from listings
+	if got := cfg.MatchWildcard("test:*"); len(got) != 1 || got[0] != "test:unit" {
+		t.Errorf("MatchWildcard(test:*) = %v, want [test:unit]", got)
+	}
+	if got := cfg.ListNamespace("test"); len(got) != 1 {
+		t.Errorf("ListNamespace(test) = %v, want 1 command", got)
+	}
+	for _, info := range cfg.GetCommandsInfo() {
+		if cfg.IsPrivate(info.Name) {
+			t.Errorf("GetCommandsInfo included private command %q", info.Name)
+		}
+	}
+
+	// Shown when private commands are requested
+	cfg.SetShowPrivate(true)
+	if got := len(cfg.GetCommandsInfo()); got != 4 {
+		t.Errorf("GetCommandsInfo with private = %d commands, want 4", got)
+	}
+
+	// ... but never watched by watch --all
+	if got := cfg.GetWatchedCommands(); !reflect.DeepEqual(got, []string{"build"}) {
+		t.Errorf("GetWatchedCommands() = %v, want [build]", got)
+	}
+	cfg.SetShowPrivate(false)
+
+	// Direct invocation is refused, running as a dependency is allowed
+	opts := RunOptions{Quiet: true}
+	if err := cfg.RunCommandWithOptions("_gen", opts); err == nil {
+		t.Error("expected direct invocation of private command to fail")
+	}
+	if err := cfg.RunCommandWithOptions("build", opts); err != nil {
+		t.Errorf("running private dependency failed: %v", err)
+	}
+	opts.All = true
+	if err := cfg.RunCommandWithOptions("_gen", opts); err != nil {
+		t.Errorf("expected --all to allow private command, got %v", err)
+	}
+}
+
+// Test watch mode settings
+func TestGetWatchMode(t *testing.T) {
+	cfg := &Config{
+		Commands: map[string]Command{
+			"serve": {WatchMode: "restart", Alias: []string{"s"}},
+			"build": {},
+		},
+	}
+	cfg.buildAliasMap()
+
+	if got := cfg.GetWatchMode("serve"); got != "restart" {
+		t.Errorf("GetWatchMode(serve) = %q, want restart", got)
+	}
+	if got := cfg.GetWatchMode("s"); got != "restart" {
+		t.Errorf("GetWatchMode(s) = %q, want restart", got)
+	}
+	if got := cfg.GetWatchMode("build"); got != "queue" {
+		t.Errorf("GetWatchMode(build) = %q, want queue", got)
+	}
+
+	cfg.Settings.WatchMode = "restart"
+	if got := cfg.GetWatchMode("build"); got != "restart" {
+		t.Errorf("GetWatchMode(build) with settings = %q, want restart", got)
+	}
+
+	cfg.Commands["bad"] = Command{WatchMode: "sometimes"}
+	if errors := cfg.Validate(); len(errors) != 1 {
+		t.Errorf("expected 1 validation error for invalid watch_mode, got %v", errors)
+	}
+}
+
+// Test watch_debounce resolution and {{changed_files}}
+func TestWatchDebounceAndChangedFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	out := filepath.Join(tmpDir, "out")
+	cfg := &Config{
+		Settings: Settings{WatchDebounce: "1s"},
+		Commands: map[string]Command{
+			"test": {
+				Run:           PlatformRun{Default: []string{"echo {{changed_files}} > " + out}},
+				WatchDebounce: "50ms",
+			},
+			"lint": {},
+		},
+		configDir: tmpDir,
+	}
+	cfg.buildAliasMap()
+
+	if d, err := cfg.GetWatchDebounce("test"); err != nil || d != 50*time.Millisecond {
+		t.Errorf("GetWatchDebounce(test) = %v, %v, want 50ms", d, err)
+	}
+	if d, err := cfg.GetWatchDebounce("lint"); err != nil || d != time.Second {
+		t.Errorf("GetWatchDebounce(lint) = %v, %v, want 1s", d, err)
+	}
+
+	opts := RunOptions{Quiet: true, ChangedFiles: []string{"a.go", "pkg/b.go"}}
+	if err := cfg.RunCommandWithOptions("test", opts); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(out)
+	if got := strings.TrimSpace(string(data)); got != "a.go pkg/b.go" {
+		t.Errorf("{{changed_files}} = %q, want %q", got, "a.go pkg/b.go")
+	}
+
+	// Each path stays one argument, and is relative to the command's dir
+	if err := os.Mkdir(filepath.Join(tmpDir, "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+	cfg.Commands["args"] = Command{Dir: "pkg", Run: PlatformRun{Default: []string{"printf '%s\\n' {{changed_files}} > " + out}}}
+	opts.ChangedFiles = []string{"pkg/with space.go", "pkg/$(touch pwned).go", "pkg/it's.go", "main.go"}
+	if err := cfg.RunCommandWithOptions("args", opts); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(out)
+	want := "with space.go\n$(touch pwned).go\nit's.go\n../main.go\n"
+	if string(data) != want {
+		t.Errorf("{{changed_files}} passed %q, want %q", data, want)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "pkg", "pwned")); err == nil {
+		t.Error("a changed file name was run as a command")
+	}
+
+	cfg.Commands["bad"] = Command{WatchDebounce: "soon"}
+	if errors := cfg.Validate(); len(errors) != 1 {
+		t.Errorf("expected 1 validation error for invalid watch_debounce, got %v", errors)
+	}
+}
+
+// Test watch_backend and watch_poll_interval settings
+func TestWatchBackendSettings(t *testing.T) {
+	cfg := &Config{Commands: map[string]Command{}}
+	if cfg.UsePollingWatcher() {
+		t.Error("polling should be off by default")
+	}
+	if d, err := cfg.GetWatchPollInterval(); err != nil || d != 0 {
+		t.Errorf("GetWatchPollInterval() = %v, %v, want 0", d, err)
+	}
+
+	cfg.Settings = Settings{WatchBackend: "poll", WatchPollInterval: "2s"}
+	if !cfg.UsePollingWatcher() {
+		t.Error("expected polling with watch_backend = \"poll\"")
+	}
+	if d, err := cfg.GetWatchPollInterval(); err != nil || d != 2*time.Second {
+		t.Errorf("GetWatchPollInterval() = %v, %v, want 2s", d, err)
+	}
+	if errors := cfg.Validate(); len(errors) != 0 {
+		t.Errorf("unexpected validation errors: %v", errors)
+	}
+
+	cfg.Settings = Settings{WatchBackend: "inotify", WatchPollInterval: "often"}
+	if errors := cfg.Validate(); len(errors) != 2 {
+		t.Errorf("expected 2 validation errors, got %v", errors)
+	}
+}
+
+// Test that watch_deps watches the dependency graph and re-runs only what changed
+func TestWatchDeps(t *testing.T) {
+	tmpDir := t.TempDir()
+	touch := func(name string) []string {
+		return []string{"touch " + filepath.Join(tmpDir, name)}
+	}
+	cfg := &Config{
+		Commands: map[string]Command{
+			"dev":    {Run: PlatformRun{Default: touch("dev")}, Watch: []string{"cmd/**/*.go"}, Dep: []string{"build", "gen"}},
+			"build":  {Run: PlatformRun{Default: touch("build")}, Watch: []string{"**/*.go"}, Dep: []string{"schema"}},
+			"gen":    {Run: PlatformRun{Default: touch("gen")}, Watch: []string{"api/*.proto", "**/*.go"}},
+			"schema": {Run: PlatformRun{Default: touch("schema")}, Watch: []string{"schema.sql"}},
+		},
+		configDir: tmpDir,
+	}
+	cfg.buildAliasMap()
+
+	if got := cfg.GetWatchPatterns("dev"); !reflect.DeepEqual(got, []string{"cmd/**/*.go"}) {
+		t.Errorf("GetWatchPatterns() without watch_deps = %v", got)
+	}
+	if got := cfg.AffectedCommands("dev", []string{"schema.sql"}); got != nil {
+		t.Errorf("AffectedCommands() without watch_deps = %v, want nil", got)
+	}
+
+	cfg.Settings.WatchDeps = true
+	want := []string{"cmd/**/*.go", "**/*.go", "schema.sql", "api/*.proto"}
+	if got := cfg.GetWatchPatterns("dev"); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetWatchPatterns() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		changed []string
+		want    map[string]bool
+	}{
+		{nil, nil},
+		{[]string{"cmd/dev/main.go"}, nil},
+		{[]string{"schema.sql"}, map[string]bool{"schema": true, "build": true}},
+		{[]string{"api/user.proto"}, map[string]bool{"gen": true}},
+		{[]string{"README.md"}, map[string]bool{}},
+	}
+	for _, tt := range tests {
+		if got := cfg.AffectedCommands("dev", tt.changed); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("AffectedCommands(%v) = %v, want %v", tt.changed, got, tt.want)
+		}
+	}
+
+	// Only the affected dependencies run, along with the command itself
+	opts := RunOptions{Quiet: true, Affected: cfg.AffectedCommands("dev", []string{"api/user.proto"})}
+	if err := cfg.RunCommandWithOptions("dev", opts); err != nil {
+		t.Fatal(err)
+	}
+	for name, ran := range map[string]bool{"dev": true, "gen": true, "build": false, "schema": false} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); (err == nil) != ran {
+			t.Errorf("%s ran = %v, want %v", name, err == nil, ran)
+		}
+	}
+}
+
+// Test that cancelling the context stops a running command
+func TestRunCommandContextCancel(t *testing.T) {
+	tmpDir := t.TempDir()
+	marker := filepath.Join(tmpDir, "marker")
+	cfg := &Config{
+		Commands: map[string]Command{
+			"serve": {Run: PlatformRun{Default: []string{"sleep 10", "touch " + marker}}},
+		},
+		configDir: tmpDir,
+	}
+	cfg.buildAliasMap()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := cfg.RunCommandContext(ctx, "serve", RunOptions{Quiet: true})
+	if err == nil {
+		t.Fatal("expected cancelled command to return an error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancellation took %v", elapsed)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("steps after cancellation should not run")
+	}
+}
+
+// Test that commands running at the same time, e.g. several watched ones, each
+// get their own working directory and environment
+func TestConcurrentRunsKeepDirAndEnv(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"api", "web"} {
+		if err := os.Mkdir(filepath.Join(tmpDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "web.env"), []byte("FROM_FILE=web\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(name string) []string {
+		return []string{"sleep 0.2", `echo "$PWD $NAME $FROM_FILE" > ` + filepath.Join(tmpDir, name+".out")}
+	}
+	cfg := &Config{
+		Commands: map[string]Command{
+			"api": {Dir: "api", Env: map[string]string{"NAME": "api"}, Run: PlatformRun{Default: run("api")}},
+			"web": {
+				Dir:     "web",
+				Env:     map[string]string{"NAME": "web"},
+				EnvFile: []string{"web.env"},
+				Run:     PlatformRun{Default: run("web")},
+			},
+		},
+		configDir: tmpDir,
+	}
+	cfg.buildAliasMap()
+
+	wd, _ := os.Getwd()
+	errs := make(chan error, 2)
+	for _, name := range []string{"api", "web"} {
+		go func() { errs <- cfg.RunCommandWithOptions(name, RunOptions{Quiet: true}) }()
+	}
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, want := range map[string]string{
+		"api": filepath.Join(tmpDir, "api") + " api ",
+		"web": filepath.Join(tmpDir, "web") + " web web",
+	} {
+		got, err := os.ReadFile(filepath.Join(tmpDir, name+".out"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(got)) != strings.TrimSpace(want) {
+			t.Errorf("%s ran with %q, want %q", name, strings.TrimSpace(string(got)), want)
+		}
+	}
+
+	// imlazy's own directory and environment are left alone
+	if now, _ := os.Getwd(); now != wd {
+		t.Errorf("working directory changed to %s", now)
+	}
+	if _, ok := os.LookupEnv("FROM_FILE"); ok {
+		t.Error("env file was loaded into imlazy's own environment")
+	}
+}
+
+// Test that ignore rules apply to if_changed hashing
+func TestHashMatchingFilesIgnore(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"main.go", "vendor/lib.go", "gen/types.go"} {
+		path := filepath.Join(tmpDir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("package x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, ".gitignore"), []byte("vendor/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	cfg := &Config{
+		Settings: Settings{Ignore: []string{"gen/"}},
+		Commands: map[string]Command{
+			"build": {IfChanged: []string{"**/*.go"}},
+		},
+		configDir: tmpDir,
+	}
+	cfg.buildAliasMap()
+	matcher := cfg.IgnoreMatcher("build")
+
+	before, err := cfg.hashMatchingFiles([]string{"**/*.go"}, matcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Touching ignored files doesn't change the hash
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(tmpDir, "vendor/lib.go"), future, future)
+	os.Chtimes(filepath.Join(tmpDir, "gen/types.go"), future, future)
+	after, _ := cfg.hashMatchingFiles([]string{"**/*.go"}, matcher)
+	if before != after {
+		t.Error("hash changed after touching ignored files")
+	}
+
+	// Touching a tracked file does
+	os.Chtimes(filepath.Join(tmpDir, "main.go"), future, future)
+	after, _ = cfg.hashMatchingFiles([]string{"**/*.go"}, matcher)
+	if before == after {
+		t.Error("hash did not change after touching main.go")
+	}
+}
+
+// Test ignore rules from the config directory apply when run from a subdirectory
+func TestIgnoreMatcherFromSubdirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"lazy.toml":         "[settings]\nignore = [\"/sub/gen/\"]\n\n[commands.build]\nrun = [\"true\"]\nif_changed = [\"**/*.go\"]\n",
+		".gitignore":        "vendor/\n",
+		"sub/main.go":       "package main\n",
+		"sub/vendor/lib.go": "package lib\n",
+		"sub/gen/types.go":  "package gen\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldWd, _ := os.Getwd()
+	os.Chdir(filepath.Join(tmpDir, "sub"))
+	defer os.Chdir(oldWd)
+
+	var cfg Config
+	parsed, err := cfg.readTomlFromPath("../lazy.toml", map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed.buildAliasMap()
+	matcher := parsed.IgnoreMatcher("build")
+
+	tests := []struct {
+		path    string
+		ignored bool
+	}{
+		{"sub/main.go", false},
+		{"sub/vendor/lib.go", true},
+		{"sub/gen/types.go", true},
+	}
+	for _, tt := range tests {
+		if got := matcher.Match(filepath.Join(tmpDir, tt.path), false); got != tt.ignored {
+			t.Errorf("Match(%s) = %v, want %v", tt.path, got, tt.ignored)
+		}
+	}
+
+	before, err := parsed.hashMatchingFiles([]string{"**/*.go"}, matcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(tmpDir, "sub/vendor/lib.go"), future, future)
+	os.Chtimes(filepath.Join(tmpDir, "sub/gen/types.go"), future, future)
+	if after, _ := parsed.hashMatchingFiles([]string{"**/*.go"}, matcher); before != after {
+		t.Error("hash changed after touching ignored files")
+	}
+}
+
+// Test negated if_changed patterns and ** include globs
+func TestGlobPatterns(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"lazy.toml":             "[settings]\ninclude = [\"lazy/**/*.toml\"]\n",
+		"lazy/a.toml":           "[commands.a]\nrun = [\"echo a\"]\n",
+		"lazy/nested/b.toml":    "[commands.b]\nrun = [\"echo b\"]\n",
+		"main.go":               "package main\n",
+		"parser/parser_test.go": "package parser\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	var cfg Config
+	parsed, err := cfg.readTomlFromPath(filepath.Join(tmpDir, "lazy.toml"), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if _, ok := parsed.Commands[name]; !ok {
+			t.Errorf("command '%s' from included file not loaded", name)
+		}
+	}
+
+	patterns := []string{"**/*.go", "!**/*_test.go"}
+	before, _ := parsed.hashMatchingFiles(patterns, nil)
+
+	// Touching an excluded file doesn't change the hash
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(tmpDir, "parser/parser_test.go"), future, future)
+	after, _ := parsed.hashMatchingFiles(patterns, nil)
+	if before != after {
+		t.Error("hash changed after touching an excluded file")
+	}
+
+	os.Chtimes(filepath.Join(tmpDir, "main.go"), future, future)
+	after, _ = parsed.hashMatchingFiles(patterns, nil)
+	if before == after {
+		t.Error("hash did not change after touching main.go")
+	}
+}
@@ -0,0 +1,229 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Requires lists preconditions that must hold before a command runs
+type Requires struct {
+	Tools []string `toml:"tools"` // Executables on PATH, optionally with a version constraint (e.g., "docker>=24")
+	Env   []string `toml:"env"`   // Environment variables that must be set
+	Files []string `toml:"files"` // Files that must exist (relative to the config file)
+}
+
+// IsEmpty returns true if no requirements are declared
+func (r Requires) IsEmpty() bool {
+	return len(r.Tools) == 0 && len(r.Env) == 0 && len(r.Files) == 0
+}
+
+// RequirementsError aggregates every unmet requirement of a command
+type RequirementsError struct {
+	Command  string
+	Problems []string
+}
+
+func (e *RequirementsError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "requirements not met for '%s':", e.Command)
+	for _, problem := range e.Problems {
+		fmt.Fprintf(&b, "\n  - %s", problem)
+	}
+	return b.String()
+}
+
+// toolRequirement is a parsed entry of requires.tools
+type toolRequirement struct {
+	Name    string
+	Op      string
+	Version string
+}
+
+var toolRequirementRe = regexp.MustCompile(`^([^<>=\s]+)\s*(?:(>=|<=|==|=|>|<)\s*(\d[\w.-]*))?$`)
+
+// parseToolRequirement parses "name", "name>=1.2" and similar constraints
+func parseToolRequirement(spec string) (toolRequirement, error) {
+	m := toolRequirementRe.FindStringSubmatch(strings.TrimSpace(spec))
+	if m == nil {
+		return toolRequirement{}, fmt.Errorf("invalid tool requirement '%s'", spec)
+	}
+	req := toolRequirement{Name: m[1], Op: m[2], Version: m[3]}
+	if req.Op == "==" {
+		req.Op = "="
+	}
+	return req, nil
+}
+
+var versionRe = regexp.MustCompile(`\d+(?:\.\d+)+|\d+`)
+
+// toolVersionTimeout bounds each attempt to read a tool's version, so a tool
+// that ignores the flag and waits for input doesn't hang the command
+var toolVersionTimeout = 5 * time.Second
+
+// toolVersion runs the tool to find its version, trying the common flag styles.
+// The next style is only tried if the tool rejects the previous one by exiting
+// with an error.
+func toolVersion(path string) string {
+	for _, args := range [][]string{{"--version"}, {"version"}, {"-version"}} {
+		ctx, cancel := context.WithTimeout(context.Background(), toolVersionTimeout)
+		cmd := exec.CommandContext(ctx, path, args...)
+		cmd.Stdin = nil                    // Read from /dev/null
+		cmd.WaitDelay = toolVersionTimeout // Don't wait on children still holding the output open
+		out, err := cmd.CombinedOutput()
+		timedOut := ctx.Err() != nil
+		cancel()
+
+		if err == nil {
+			return versionRe.FindString(string(out))
+		}
+		var exitErr *exec.ExitError
+		if timedOut || !errors.As(err, &exitErr) {
+			return ""
+		}
+	}
+	return ""
+}
+
+// compareVersions compares dotted numeric versions, returning -1, 0 or 1
+func compareVersions(a, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x < y {
+			return -1
+		}
+		if x > y {
+			return 1
+		}
+	}
+	return 0
+}
+
+// satisfies reports whether version meets the requirement's constraint
+func (r toolRequirement) satisfies(version string) bool {
+	cmp := compareVersions(version, r.Version)
+	switch r.Op {
+	case ">=":
+		return cmp >= 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	case "<":
+		return cmp < 0
+	case "=":
+		return cmp == 0
+	}
+	return true
+}
+
+// checkRequires checks a command's requirements and returns a RequirementsError
+// listing every problem, or nil if all requirements are met. Variables in env,
+// e.g. from env files, count as set.
+func (c *Config) checkRequires(name string, cmd Command, env map[string]string) error {
+	var problems []string
+
+	for _, spec := range cmd.Requires.Tools {
+		req, err := parseToolRequirement(spec)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		path, err := exec.LookPath(req.Name)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("tool '%s' not found in PATH", req.Name))
+			continue
+		}
+		if req.Op == "" {
+			continue
+		}
+		version := toolVersion(path)
+		if version == "" {
+			problems = append(problems, fmt.Sprintf("could not determine version of '%s' (need %s%s)", req.Name, req.Op, req.Version))
+		} else if !req.satisfies(version) {
+			problems = append(problems, fmt.Sprintf("tool '%s' version %s does not satisfy %s%s", req.Name, version, req.Op, req.Version))
+		}
+	}
+
+	for _, key := range cmd.Requires.Env {
+		// Variables set by the config itself count as set
+		if _, ok := c.Env[key]; ok {
+			continue
+		}
+		if _, ok := cmd.Env[key]; ok {
+			continue
+		}
+		if _, ok := env[key]; ok {
+			continue
+		}
+		if _, ok := os.LookupEnv(key); !ok {
+			problems = append(problems, fmt.Sprintf("environment variable '%s' is not set", key))
+		}
+	}
+
+	for _, file := range cmd.Requires.Files {
+		path := c.interpolateVariables(file, nil)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(c.configDir, path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			problems = append(problems, fmt.Sprintf("file '%s' not found", file))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &RequirementsError{Command: name, Problems: problems}
+	}
+	return nil
+}
+
+// CheckAllRequires checks the requirements of every command and returns the
+// errors for commands whose requirements are not met, sorted by command name.
+// Variables from env files count as set, as they do when the command runs.
+func (c *Config) CheckAllRequires() []error {
+	var names []string
+	for name, cmd := range c.Commands {
+		if !cmd.Requires.IsEmpty() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	global := make(map[string]string)
+	if err := c.loadEnvFiles(c.Settings.EnvFile, global, RunOptions{}); err != nil {
+		return []error{fmt.Errorf("failed to load global env files: %w", err)}
+	}
+
+	var errs []error
+	for _, name := range names {
+		cmd := c.Commands[name]
+		env := make(map[string]string, len(global))
+		for key, value := range global {
+			env[key] = value
+		}
+		if err := c.loadEnvFiles(cmd.EnvFile, env, RunOptions{}); err != nil {
+			errs = append(errs, fmt.Errorf("'%s': failed to load env files: %w", name, err))
+			continue
+		}
+		if err := c.checkRequires(name, cmd, env); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
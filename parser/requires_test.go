@@ -0,0 +1,188 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseToolRequirement(t *testing.T) {
+	tests := []struct {
+		spec    string
+		name    string
+		op      string
+		version string
+		wantErr bool
+	}{
+		{"protoc", "protoc", "", "", false},
+		{"docker>=24", "docker", ">=", "24", false},
+		{"go >= 1.21.3", "go", ">=", "1.21.3", false},
+		{"node==20", "node", "=", "20", false},
+		{"make<4", "make", "<", "4", false},
+		{">=1", "", "", "", true},
+		{"docker>=", "", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			req, err := parseToolRequirement(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseToolRequirement(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if req.Name != tt.name || req.Op != tt.op || req.Version != tt.version {
+				t.Errorf("parseToolRequirement(%q) = %+v", tt.spec, req)
+			}
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"24.0.7", "24", 1},
+		{"24.0.0", "24", 0},
+		{"24.0.7", "24.1", -1},
+		{"1.21.3", "1.9", 1},
+		{"3", "3.0.0", 0},
+	}
+
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.expected {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+func TestToolVersion(t *testing.T) {
+	oldTimeout := toolVersionTimeout
+	defer func() { toolVersionTimeout = oldTimeout }()
+	toolVersionTimeout = 500 * time.Millisecond
+
+	tests := []struct {
+		name     string
+		script   string
+		expected string
+	}{
+		{"dashdash", `[ "$1" = --version ] && echo "tool 1.2.3"`, "1.2.3"},
+		{"subcommand", `[ "$1" = version ] || { echo "usage: tool" >&2; exit 2; }; echo "v4.5"`, "4.5"},
+		{"no fallback after success", `[ "$1" = --version ] && echo "unknown"; [ "$1" = version ] && echo "9.9"; exit 0`, ""},
+		{"reads stdin", `read line && echo "$line 1.0"`, ""},
+		{"hangs", `sleep 10`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tool")
+			if err := os.WriteFile(path, []byte("#!/bin/sh\n"+tt.script+"\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			start := time.Now()
+			if got := toolVersion(path); got != tt.expected {
+				t.Errorf("toolVersion() = %q, want %q", got, tt.expected)
+			}
+			if time.Since(start) > 2*time.Second {
+				t.Errorf("toolVersion took %v, want it cut short by the timeout", time.Since(start))
+			}
+		})
+	}
+}
+
+func TestCheckRequires(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("IMLAZY_TEST_SET", "1")
+	defer os.Unsetenv("IMLAZY_TEST_SET")
+	os.Unsetenv("IMLAZY_TEST_UNSET")
+
+	cfg := &Config{
+		Env:       map[string]string{"FROM_CONFIG": "1"},
+		configDir: tmpDir,
+	}
+
+	ok := Command{Requires: Requires{
+		Tools: []string{"sh"},
+		Env:   []string{"IMLAZY_TEST_SET", "FROM_CONFIG"},
+		Files: []string{"go.mod"},
+	}}
+	if err := cfg.checkRequires("ok", ok, nil); err != nil {
+		t.Errorf("checkRequires returned error: %v", err)
+	}
+
+	bad := Command{Requires: Requires{
+		Tools: []string{"imlazy-no-such-tool"},
+		Env:   []string{"IMLAZY_TEST_UNSET"},
+		Files: []string{"missing.txt"},
+	}}
+	err := cfg.checkRequires("bad", bad, nil)
+	reqErr, isReqErr := err.(*RequirementsError)
+	if !isReqErr {
+		t.Fatalf("expected RequirementsError, got %v", err)
+	}
+	if len(reqErr.Problems) != 3 {
+		t.Errorf("expected 3 problems, got %d: %v", len(reqErr.Problems), reqErr.Problems)
+	}
+}
+
+func TestRequiresCheckedBeforeDeps(t *testing.T) {
+	tmpDir := t.TempDir()
+	marker := filepath.Join(tmpDir, "marker")
+	cfg := &Config{
+		Commands: map[string]Command{
+			"dep": {Run: PlatformRun{Default: []string{"touch " + marker}}},
+			"main": {
+				Dep:      []string{"dep"},
+				Run:      PlatformRun{Default: []string{"true"}},
+				Requires: Requires{Files: []string{"missing.txt"}},
+			},
+		},
+		configDir: tmpDir,
+	}
+	cfg.buildAliasMap()
+
+	if err := cfg.RunCommandWithOptions("main", RunOptions{Quiet: true}); err == nil {
+		t.Error("expected unmet requirements to fail the command")
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("dependency should not run when requirements are not met")
+	}
+
+	errs := cfg.CheckAllRequires()
+	if len(errs) != 1 {
+		t.Errorf("CheckAllRequires returned %d errors, want 1", len(errs))
+	}
+}
+
+func TestCheckAllRequiresEnvFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, ".env"), []byte("FROM_DOTENV=1\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, ".env.deploy"), []byte("FROM_CMD_DOTENV=1\n"), 0644)
+	os.Unsetenv("FROM_DOTENV")
+	os.Unsetenv("FROM_CMD_DOTENV")
+
+	cfg := &Config{
+		Settings: Settings{EnvFile: []string{".env"}},
+		Commands: map[string]Command{
+			"deploy": {
+				EnvFile:  []string{".env.deploy"},
+				Requires: Requires{Env: []string{"FROM_DOTENV", "FROM_CMD_DOTENV"}},
+			},
+			// The command's env file only applies to that command
+			"other": {Requires: Requires{Env: []string{"FROM_CMD_DOTENV"}}},
+		},
+		configDir: tmpDir,
+	}
+
+	errs := cfg.CheckAllRequires()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "other") {
+		t.Errorf("CheckAllRequires() = %v, want only 'other' to fail", errs)
+	}
+}
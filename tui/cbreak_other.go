@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package tui
+
+import "errors"
+
+// enableCbreak is not supported on this platform, so watch keys are disabled
+func enableCbreak(fd int) (func(), error) {
+	return nil, errors.New("watch keys are not supported on this platform")
+}
@@ -0,0 +1,453 @@
+package tui
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/javanhut/imlazy/parser"
+)
+
+// Dashboard styles
+var (
+	runningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+	passedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	failedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+
+	paneStyle = lipgloss.NewStyle().
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderLeft(true).
+			BorderForeground(lipgloss.Color("240")).
+			PaddingLeft(1)
+)
+
+// maxOutput is how much output is kept per command; older output is dropped
+const maxOutput = 256 * 1024
+
+// stopGracePeriod is how long commands get to exit after quitting before they
+// are killed. It is a little longer than imlazy gives the processes of a
+// cancelled command, so those are stopped first.
+const stopGracePeriod = 6 * time.Second
+
+// outputWaitDelay is how long a command's output is still read after it
+// exits, in case something it started in the background holds it open
+const outputWaitDelay = time.Second
+
+// runStatus is the state of a command in the dashboard
+type runStatus int
+
+const (
+	statusIdle runStatus = iota
+	statusRunning
+	statusPassed
+	statusFailed
+)
+
+// dashRun tracks the latest run of a command
+type dashRun struct {
+	id       int // Tells output of the current run apart from earlier ones
+	status   runStatus
+	output   []byte
+	started  time.Time
+	duration time.Duration
+	err      error
+	proc     *exec.Cmd
+	done     chan struct{} // Closed when the process has exited
+}
+
+// outputMsg carries output written by a running command
+type outputMsg struct {
+	name string
+	id   int
+	data []byte
+}
+
+// doneMsg reports that a command has exited
+type doneMsg struct {
+	name string
+	id   int
+	err  error
+}
+
+// dashboard is the model of the `imlazy ui` dashboard
+type dashboard struct {
+	commands []parser.CommandInfo
+	runs     map[string]*dashRun
+	cursor   int
+	nextID   int
+	exe      string   // imlazy binary used to run commands
+	flags    []string // Global flags passed to every run
+	events   chan tea.Msg
+	pane     viewport.Model
+	width    int
+	height   int
+	ready    bool
+}
+
+// eventWriter forwards a command's output to the dashboard
+type eventWriter struct {
+	name   string
+	id     int
+	events chan tea.Msg
+}
+
+func (w eventWriter) Write(p []byte) (int, error) {
+	w.events <- outputMsg{name: w.name, id: w.id, data: append([]byte(nil), p...)}
+	return len(p), nil
+}
+
+// listen waits for the next event from a running command
+func listen(events chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-events
+	}
+}
+
+// Init starts listening for command events
+func (m dashboard) Init() tea.Cmd {
+	return listen(m.events)
+}
+
+// Update handles messages
+func (m dashboard) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q":
+			m.stopAll()
+			return m, tea.Quit
+
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+				m.refreshPane(true)
+			}
+
+		case "down", "j":
+			if m.cursor < len(m.commands)-1 {
+				m.cursor++
+				m.refreshPane(true)
+			}
+
+		case "enter", "r":
+			m.start(m.commands[m.cursor].Name)
+			m.refreshPane(true)
+
+		case "x":
+			m.stop(m.commands[m.cursor].Name)
+
+		case "pgup":
+			m.pane.ViewUp()
+		case "pgdown":
+			m.pane.ViewDown()
+		case "home":
+			m.pane.GotoTop()
+		case "end":
+			m.pane.GotoBottom()
+		}
+		return m, nil
+
+	case tea.MouseMsg:
+		var cmd tea.Cmd
+		m.pane, cmd = m.pane.Update(msg)
+		return m, cmd
+
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.resize()
+		return m, nil
+
+	case outputMsg:
+		if run := m.runs[msg.name]; run != nil && run.id == msg.id {
+			run.output = appendOutput(run.output, msg.data)
+			if m.selected() == msg.name {
+				m.refreshPane(false)
+			}
+		}
+		return m, listen(m.events)
+
+	case doneMsg:
+		if run := m.runs[msg.name]; run != nil && run.id == msg.id {
+			run.duration = time.Since(run.started).Round(time.Millisecond)
+			run.err = msg.err
+			run.proc = nil
+			if msg.err != nil {
+				run.status = statusFailed
+			} else {
+				run.status = statusPassed
+			}
+		}
+		return m, listen(m.events)
+	}
+
+	return m, nil
+}
+
+// selected returns the name of the command under the cursor
+func (m dashboard) selected() string {
+	return m.commands[m.cursor].Name
+}
+
+// start runs a command in the background unless it's already running
+func (m *dashboard) start(name string) {
+	run := m.runs[name]
+	if run != nil && run.status == statusRunning {
+		return
+	}
+
+	m.nextID++
+	run = &dashRun{
+		id:      m.nextID,
+		status:  statusRunning,
+		started: time.Now(),
+		done:    make(chan struct{}),
+	}
+	m.runs[name] = run
+
+	args := append(append([]string{}, m.flags...), name)
+	proc := exec.Command(m.exe, args...)
+	writer := eventWriter{name: name, id: run.id, events: m.events}
+	proc.Stdout = writer
+	proc.Stderr = writer
+	proc.WaitDelay = outputWaitDelay
+	if err := proc.Start(); err != nil {
+		run.status = statusFailed
+		run.err = err
+		run.output = []byte(fmt.Sprintf("Failed to start: %v\n", err))
+		close(run.done)
+		return
+	}
+	run.proc = proc
+
+	go func() {
+		err := proc.Wait()
+		m.events <- doneMsg{name: name, id: run.id, err: err}
+		close(run.done)
+	}()
+}
+
+// stop asks a running command to stop, the same way Ctrl+C would
+func (m *dashboard) stop(name string) {
+	run := m.runs[name]
+	if run == nil || run.proc == nil {
+		return
+	}
+	if err := run.proc.Process.Signal(syscall.SIGTERM); err != nil {
+		run.proc.Process.Kill()
+	}
+}
+
+// stopAll stops every running command
+func (m *dashboard) stopAll() {
+	for name := range m.runs {
+		m.stop(name)
+	}
+}
+
+// waitStopped waits for stopped commands to exit, killing the ones still
+// running after grace
+func (m dashboard) waitStopped(grace time.Duration) {
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	for _, run := range m.runs {
+		select {
+		case <-run.done:
+			continue
+		case <-timer.C:
+			for _, run := range m.runs {
+				if run.proc != nil {
+					run.proc.Process.Kill()
+				}
+			}
+		}
+		<-run.done
+	}
+}
+
+// appendOutput adds data to a command's output, dropping the oldest lines once
+// it grows past maxOutput
+func appendOutput(out, data []byte) []byte {
+	out = append(out, bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))...)
+	if len(out) <= maxOutput {
+		return out
+	}
+	cut := len(out) - maxOutput
+	if i := bytes.IndexByte(out[cut:], '\n'); i >= 0 {
+		cut += i + 1
+	}
+	return append([]byte(nil), out[cut:]...)
+}
+
+// listWidth is the width of the command list column
+func (m dashboard) listWidth() int {
+	width := 20
+	for _, cmd := range m.commands {
+		if w := len(cmd.Name) + 4; w > width {
+			width = w
+		}
+	}
+	if max := m.width / 3; width > max {
+		width = max
+	}
+	return width
+}
+
+// resize fits the output pane to the window
+func (m *dashboard) resize() {
+	width := m.width - m.listWidth() - 3
+	height := m.height - 5
+	if width < 10 {
+		width = 10
+	}
+	if height < 3 {
+		height = 3
+	}
+	if !m.ready {
+		m.pane = viewport.New(width, height)
+		m.ready = true
+	} else {
+		m.pane.Width = width
+		m.pane.Height = height
+	}
+	m.refreshPane(true)
+}
+
+// refreshPane shows the output of the selected command. The pane follows new
+// output while it's scrolled to the bottom, or when jump is set.
+func (m *dashboard) refreshPane(jump bool) {
+	if !m.ready {
+		return
+	}
+	follow := jump || m.pane.AtBottom()
+
+	content := dimStyle.Render("Not run yet, press enter to run")
+	if run := m.runs[m.selected()]; run != nil {
+		if len(run.output) == 0 {
+			content = dimStyle.Render("No output")
+		} else {
+			content = lipgloss.NewStyle().Width(m.pane.Width).Render(string(run.output))
+		}
+	}
+	m.pane.SetContent(content)
+	if follow {
+		m.pane.GotoBottom()
+	}
+}
+
+// statusIcon renders the status of a command's latest run
+func statusIcon(run *dashRun) string {
+	if run == nil {
+		return dimStyle.Render("·")
+	}
+	switch run.status {
+	case statusRunning:
+		return runningStyle.Render("●")
+	case statusPassed:
+		return passedStyle.Render("✓")
+	case statusFailed:
+		return failedStyle.Render("✗")
+	}
+	return dimStyle.Render("·")
+}
+
+// paneTitle describes the selected command's latest run
+func (m dashboard) paneTitle() string {
+	name := m.selected()
+	run := m.runs[name]
+	title := titleStyle.Render(name)
+	switch {
+	case run == nil:
+		return title
+	case run.status == statusRunning:
+		return title + runningStyle.Render(" running")
+	case run.status == statusPassed:
+		return title + passedStyle.Render(fmt.Sprintf(" passed in %v", run.duration))
+	default:
+		return title + failedStyle.Render(fmt.Sprintf(" failed in %v: %v", run.duration, run.err))
+	}
+}
+
+// View renders the dashboard
+func (m dashboard) View() string {
+	if !m.ready {
+		return ""
+	}
+
+	// Command list, scrolled to keep the cursor visible
+	listWidth := m.listWidth()
+	visible := m.pane.Height + 1
+	start := 0
+	if m.cursor >= visible {
+		start = m.cursor - visible + 1
+	}
+	var list strings.Builder
+	for i := start; i < len(m.commands) && i < start+visible; i++ {
+		name := m.commands[i].Name
+		if len(name) > listWidth-4 {
+			name = name[:listWidth-5] + "…"
+		}
+		style := normalStyle
+		cursor := "  "
+		if i == m.cursor {
+			style = selectedStyle
+			cursor = "> "
+		}
+		list.WriteString(cursor + statusIcon(m.runs[m.commands[i].Name]) + " " + style.Render(name) + "\n")
+	}
+
+	left := lipgloss.NewStyle().Width(listWidth).Height(visible).Render(strings.TrimSuffix(list.String(), "\n"))
+	right := paneStyle.Render(m.paneTitle() + "\n" + m.pane.View())
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("ImLazy Dashboard"))
+	b.WriteString("\n\n")
+	b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, left, right))
+	b.WriteString("\n\n")
+	b.WriteString(dimStyle.Render("↑/↓ select • enter run • x stop • pgup/pgdn scroll • q quit"))
+	return b.String()
+}
+
+// RunDashboard opens a dashboard listing the commands. Selected commands run in
+// the background, each as its own imlazy process with flags prepended, and
+// their output is shown in a scrollable pane. Quitting stops running commands.
+func RunDashboard(cfg *parser.Config, flags []string) error {
+	commands := cfg.GetCommandsInfo()
+	if len(commands) == 0 {
+		return errors.New("no commands defined")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot find the imlazy binary: %w", err)
+	}
+
+	m := dashboard{
+		commands: commands,
+		runs:     make(map[string]*dashRun),
+		exe:      exe,
+		flags:    flags,
+		events:   make(chan tea.Msg, 64),
+	}
+	final, err := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion()).Run()
+	if err != nil {
+		m.stopAll()
+	}
+	if f, ok := final.(dashboard); ok {
+		m = f
+	}
+
+	// Wait for stopped commands to exit, discarding their remaining output
+	go func() {
+		for range m.events {
+		}
+	}()
+	m.waitStopped(stopGracePeriod)
+	return err
+}
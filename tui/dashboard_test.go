@@ -0,0 +1,42 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/javanhut/imlazy/parser"
+)
+
+func TestDashboardQuitKillsStuckRun(t *testing.T) {
+	// Commands run as "sh -c script name"; this one ignores SIGTERM
+	m := dashboard{
+		commands: []parser.CommandInfo{{Name: "stuck"}},
+		runs:     make(map[string]*dashRun),
+		exe:      "sh",
+		flags:    []string{"-c", "trap '' TERM; echo started; sleep 5"},
+		events:   make(chan tea.Msg, 64),
+	}
+	m.start("stuck")
+	if msg := <-m.events; msg.(outputMsg).name != "stuck" {
+		t.Fatalf("unexpected first event %#v", msg)
+	}
+
+	model, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	if cmd == nil || cmd() != tea.Quit() {
+		t.Error("q did not quit the dashboard")
+	}
+
+	go func() {
+		for range m.events {
+		}
+	}()
+	start := time.Now()
+	model.(dashboard).waitStopped(200 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("waiting for the stuck run took %v, want it killed after the grace period", elapsed)
+	}
+	if run := m.runs["stuck"]; run.proc.ProcessState == nil || run.proc.ProcessState.Success() {
+		t.Errorf("stuck run state = %v, want it killed", run.proc.ProcessState)
+	}
+}
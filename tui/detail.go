@@ -0,0 +1,164 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/javanhut/imlazy/parser"
+)
+
+// sideBySideWidth is the window width from which details are shown next to the
+// list instead of below it
+const sideBySideWidth = 100
+
+var labelStyle = lipgloss.NewStyle().
+	Bold(true).
+	Foreground(lipgloss.Color("245"))
+
+// renderDetails describes a command for the detail pane, wrapped to width
+func renderDetails(cfg *parser.Config, info parser.CommandInfo, width int) string {
+	if width < 20 {
+		width = 20
+	}
+	wrap := lipgloss.NewStyle().Width(width - 2)
+
+	var b strings.Builder
+	section := func(label string, lines ...string) {
+		if len(lines) == 0 {
+			return
+		}
+		b.WriteString(labelStyle.Render(label))
+		b.WriteString("\n")
+		for _, line := range lines {
+			for _, wrapped := range strings.Split(wrap.Render(line), "\n") {
+				b.WriteString("  " + strings.TrimRight(wrapped, " ") + "\n")
+			}
+		}
+	}
+
+	b.WriteString(titleStyle.Render(info.Name))
+	if info.Description != "" {
+		b.WriteString(" " + dimStyle.Render(info.Description))
+	}
+	b.WriteString("\n")
+
+	var run []string
+	for _, line := range cfg.ResolveRun(info.Name) {
+		run = append(run, previewStyle.Render("$ "+line))
+	}
+	section("Run", run...)
+	section("Deps", depTree(cfg, info.Deps, "", map[string]bool{info.Name: true})...)
+
+	var env []string
+	for key, value := range info.Env {
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env)
+	section("Env", env...)
+
+	if info.Dir != "" {
+		section("Dir", info.Dir)
+	}
+	var limits []string
+	if info.Timeout != "" {
+		limits = append(limits, "timeout "+info.Timeout)
+	}
+	if info.Retry > 0 {
+		retry := fmt.Sprintf("retry %d×", info.Retry)
+		if info.RetryDelay != "" {
+			retry += ", " + info.RetryDelay + " apart"
+		}
+		limits = append(limits, retry)
+	}
+	if len(limits) > 0 {
+		section("Limits", strings.Join(limits, " • "))
+	}
+	if len(info.Watch) > 0 {
+		section("Watch", strings.Join(info.Watch, "  "))
+	}
+	if len(info.Aliases) > 0 {
+		section("Aliases", strings.Join(info.Aliases, ", "))
+	}
+
+	if entry, ok := cfg.GetLastRun(info.Name); ok {
+		section("Last run", lastRunSummary(entry))
+	} else {
+		section("Last run", dimStyle.Render("never"))
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// depTree renders dependencies as a tree, one line per dependency. visiting
+// holds the commands on the current path, to stop at cycles.
+func depTree(cfg *parser.Config, deps []string, indent string, visiting map[string]bool) []string {
+	var lines []string
+	for i, dep := range deps {
+		branch, next := "├─ ", "│  "
+		if i == len(deps)-1 {
+			branch, next = "└─ ", "   "
+		}
+
+		name := cfg.ResolveCommandName(dep)
+		cmd, ok := cfg.GetCommand(name)
+		switch {
+		case visiting[name]:
+			lines = append(lines, indent+branch+dep+dimStyle.Render(" (cycle)"))
+		case !ok:
+			lines = append(lines, indent+branch+dep+failedStyle.Render(" (undefined)"))
+		default:
+			lines = append(lines, indent+branch+dep)
+			visiting[name] = true
+			lines = append(lines, depTree(cfg, cmd.Dep, indent+next, visiting)...)
+			visiting[name] = false
+		}
+	}
+	return lines
+}
+
+// lastRunSummary describes a history entry, e.g. "✓ passed 5m ago (Jan 2 15:04)"
+func lastRunSummary(entry parser.HistoryEntry) string {
+	when := fmt.Sprintf("%s (%s)", ago(entry.Timestamp), entry.Timestamp.Format("Jan 2 15:04"))
+	if len(entry.Args) > 0 {
+		when += " with " + strings.Join(entry.Args, " ")
+	}
+	if entry.ExitCode != 0 {
+		return failedStyle.Render(fmt.Sprintf("✗ failed (exit %d)", entry.ExitCode)) + " " + when
+	}
+	return passedStyle.Render("✓ passed") + " " + when
+}
+
+// ago formats how long ago t was, roughly
+func ago(t time.Time) string {
+	switch d := time.Since(t); {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
+
+// renderNamespaceDetails describes a namespace: the commands Enter would run
+func renderNamespaceDetails(cfg *parser.Config, namespace string, width int) string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(namespace + ":*"))
+	b.WriteString("\n")
+	b.WriteString(labelStyle.Render("Runs"))
+	b.WriteString("\n")
+	for _, name := range cfg.MatchWildcard(namespace + ":*") {
+		line := "  " + name
+		if cmd, ok := cfg.GetCommand(name); ok && cmd.Desc != "" {
+			line += " " + dimStyle.Render(cmd.Desc)
+		}
+		b.WriteString(lipgloss.NewStyle().MaxWidth(width).Render(line))
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,185 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/javanhut/imlazy/parser"
+)
+
+// formField is one input of the args form
+type formField struct {
+	name  string // Variable name, or "" for the args field
+	def   string // Configured value of the variable
+	input textinput.Model
+}
+
+// argsForm asks for passthrough args and variable values before running the
+// picked commands
+type argsForm struct {
+	commands []string
+	fields   []formField
+	focus    int
+}
+
+// needsForm reports whether the picked commands take input worth asking for:
+// {{args}}, variables in their run lines, or args used last time
+func needsForm(cfg *parser.Config, commands []string) bool {
+	for _, name := range commands {
+		if vars, usesArgs := cfg.ReferencedVariables(name); usesArgs || len(vars) > 0 {
+			return true
+		}
+	}
+	return len(cfg.GetLastArgs(strings.Join(commands, " "))) > 0
+}
+
+// newArgsForm creates the form for the picked commands. Args and variables
+// default to the ones used last time, variables otherwise to their configured
+// values.
+func newArgsForm(cfg *parser.Config, commands []string) *argsForm {
+	f := &argsForm{commands: commands}
+	last, _ := cfg.GetLastRun(strings.Join(commands, " "))
+
+	args := textinput.New()
+	args.Placeholder = "Arguments passed to the command, quoted as in the shell"
+	args.SetValue(strings.Join(last.Args, " "))
+	f.fields = append(f.fields, formField{input: args})
+
+	seen := make(map[string]bool)
+	for _, name := range commands {
+		vars, _ := cfg.ReferencedVariables(name)
+		for _, varName := range vars {
+			if seen[varName] {
+				continue
+			}
+			seen[varName] = true
+			input := textinput.New()
+			value, ok := last.Vars[varName]
+			if !ok {
+				value = cfg.Variables[varName]
+			}
+			input.SetValue(value)
+			f.fields = append(f.fields, formField{name: varName, def: cfg.Variables[varName], input: input})
+		}
+	}
+
+	for i := range f.fields {
+		f.fields[i].input.Width = 40
+		f.fields[i].input.Prompt = ""
+	}
+	f.fields[0].input.Focus()
+	return f
+}
+
+// update handles messages while the form is shown
+func (f *argsForm) update(msg tea.Msg) tea.Cmd {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "tab", "down":
+			f.setFocus((f.focus + 1) % len(f.fields))
+			return nil
+		case "shift+tab", "up":
+			f.setFocus((f.focus + len(f.fields) - 1) % len(f.fields))
+			return nil
+		}
+	}
+
+	var cmd tea.Cmd
+	f.fields[f.focus].input, cmd = f.fields[f.focus].input.Update(msg)
+	return cmd
+}
+
+// setFocus moves the cursor to another field
+func (f *argsForm) setFocus(i int) {
+	f.fields[f.focus].input.Blur()
+	f.focus = i
+	f.fields[f.focus].input.Focus()
+}
+
+// selection returns what was entered: the args, and the variables whose
+// value differs from the configured one
+func (f *argsForm) selection() ([]string, map[string]string) {
+	args := splitArgs(f.fields[0].input.Value())
+	if args == nil {
+		args = []string{} // Entered no args, as opposed to not asked
+	}
+
+	var vars map[string]string
+	for _, field := range f.fields[1:] {
+		if value := field.input.Value(); value != field.def {
+			if vars == nil {
+				vars = make(map[string]string)
+			}
+			vars[field.name] = value
+		}
+	}
+	return args, vars
+}
+
+// splitArgs splits the args typed in the form into words the way the shell
+// does, so quoted or escaped spaces don't end a word. Words keep their quotes
+// and escapes, since args end up in the command line as shell text.
+func splitArgs(s string) []string {
+	var args []string
+	var word strings.Builder
+	inWord, escaped := false, false
+	var quote rune
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case unicode.IsSpace(r):
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+			continue
+		}
+		word.WriteRune(r)
+		inWord = true
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args
+}
+
+// view renders the form
+func (f *argsForm) view() string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Run " + strings.Join(f.commands, ", ")))
+	b.WriteString("\n\n")
+
+	for i, field := range f.fields {
+		label := "args"
+		if field.name != "" {
+			label = field.name
+		}
+		style := normalStyle
+		cursor := "  "
+		if i == f.focus {
+			style = selectedStyle
+			cursor = "> "
+		}
+		b.WriteString(style.Render(fmt.Sprintf("%s%-16s", cursor, label)))
+		b.WriteString(field.input.View())
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(dimStyle.Render("tab/↑/↓ next field • enter run • esc back"))
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package tui
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/javanhut/imlazy/output"
+	"github.com/javanhut/imlazy/parser"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"", nil},
+		{"  -v  ./... ", []string{"-v", "./..."}},
+		{`-run "Test A"`, []string{"-run", `"Test A"`}},
+		{`'it''s here'`, []string{`'it''s here'`}},
+		{`a\ b c`, []string{`a\ b`, "c"}},
+		{`"say \"hi there\""`, []string{`"say \"hi there\""`}},
+		{`'back\ slash' x`, []string{`'back\ slash'`, "x"}},
+		{`--name="a b"c`, []string{`--name="a b"c`}},
+	}
+
+	for _, tt := range tests {
+		if got := splitArgs(tt.input); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("splitArgs(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestFormArgsReachCommand(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	config := "[commands.show]\nrun = [\"printf '[%s]' {{args}}\"]\n"
+	if err := os.WriteFile("lazy.toml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := (&parser.Config{}).ReadToml()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := newArgsForm(cfg, []string{"show"})
+	f.fields[0].input.SetValue(`-run "Test A"  a\ b 'c  d'`)
+	args, _ := f.selection()
+
+	tail := output.NewTailWriter(5)
+	if err := cfg.RunCommandWithOptions("show", parser.RunOptions{Args: args, Quiet: true, Output: tail}); err != nil {
+		t.Fatal(err)
+	}
+	if lines, want := tail.Lines(), "[-run][Test A][a b][c  d]"; len(lines) != 1 || lines[0] != want {
+		t.Errorf("command got %q, want %q", lines, want)
+	}
+}
@@ -0,0 +1,228 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/javanhut/imlazy/parser"
+)
+
+// historyView lists past runs, newest first, to pick one to run again
+type historyView struct {
+	history    []parser.HistoryEntry  // Oldest first, as stored
+	shown      []parser.NumberedEntry // Entries matching the filter, newest first
+	filter     textinput.Model
+	failedOnly bool
+	cursor     int
+}
+
+// newHistoryView loads the history for the history view
+func newHistoryView(cfg *parser.Config) (*historyView, error) {
+	history, err := cfg.GetHistory(0)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &historyView{history: history}
+
+	h.filter = textinput.New()
+	h.filter.Placeholder = "Type to filter history..."
+	h.filter.CharLimit = 50
+	h.filter.Width = 40
+	h.filter.Focus()
+	h.applyFilter()
+	return h, nil
+}
+
+// applyFilter shows the entries whose command line contains the filter text
+func (h *historyView) applyFilter() {
+	h.shown = parser.FilterHistory(h.history, parser.HistoryFilter{FailedOnly: h.failedOnly, Search: h.filter.Value()})
+	if h.cursor >= len(h.shown) {
+		h.cursor = max(len(h.shown)-1, 0)
+	}
+}
+
+// update handles keys other than enter and esc while the history is shown
+func (h *historyView) update(msg tea.Msg) tea.Cmd {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "up", "ctrl+p":
+			if h.cursor > 0 {
+				h.cursor--
+			}
+			return nil
+		case "down", "ctrl+n":
+			if h.cursor < len(h.shown)-1 {
+				h.cursor++
+			}
+			return nil
+		case "ctrl+f":
+			h.failedOnly = !h.failedOnly
+			h.applyFilter()
+			return nil
+		case "ctrl+u":
+			h.filter.SetValue("")
+			h.applyFilter()
+			return nil
+		}
+	}
+
+	var cmd tea.Cmd
+	h.filter, cmd = h.filter.Update(msg)
+	h.applyFilter()
+	return cmd
+}
+
+// selected returns the entry under the cursor
+func (h *historyView) selected() (parser.HistoryEntry, bool) {
+	if h.cursor >= len(h.shown) {
+		return parser.HistoryEntry{}, false
+	}
+	return h.shown[h.cursor].HistoryEntry, true
+}
+
+// view renders the history list
+func (h *historyView) view(width int) string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("ImLazy History"))
+	if h.failedOnly {
+		b.WriteString(" " + failedStyle.Render("failed only"))
+	}
+	b.WriteString("\n\n")
+	b.WriteString(h.filter.View())
+	b.WriteString("\n\n")
+
+	if len(h.shown) == 0 {
+		if len(h.history) == 0 {
+			b.WriteString(dimStyle.Render("No command history yet"))
+		} else {
+			b.WriteString(dimStyle.Render("No matching runs"))
+		}
+		b.WriteString("\n")
+	}
+
+	maxVisible := 10
+	start := 0
+	if h.cursor >= maxVisible {
+		start = h.cursor - maxVisible + 1
+	}
+	if width == 0 {
+		width = 80
+	}
+	line := lipgloss.NewStyle().MaxWidth(width)
+	for i := start; i < len(h.shown) && i < start+maxVisible; i++ {
+		entry := h.shown[i].HistoryEntry
+
+		cursor := "  "
+		style := normalStyle
+		if i == h.cursor {
+			cursor = "> "
+			style = selectedStyle
+		}
+
+		status := passedStyle.Render("✓")
+		if entry.ExitCode != 0 {
+			status = failedStyle.Render("✗")
+		}
+		text := fmt.Sprintf("%-30s", entry.CommandLine())
+		b.WriteString(line.Render(style.Render(cursor) + status + " " + style.Render(text) + " " + dimStyle.Render(runInfo(entry))))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(dimStyle.Render("↑/↓ navigate • enter run again • ctrl+f failed only • esc back • ctrl+u clear"))
+	return b.String()
+}
+
+// runInfo describes when a run happened, how long it took, on which branch
+// and how it ended
+func runInfo(entry parser.HistoryEntry) string {
+	parts := []string{ago(entry.Timestamp), entry.Timestamp.Format("Jan 2 15:04")}
+	if entry.Duration > 0 {
+		parts = append(parts, entry.Duration.String())
+	}
+	if entry.GitBranch != "" {
+		parts = append(parts, entry.GitBranch)
+	}
+	if entry.ExitCode != 0 {
+		parts = append(parts, fmt.Sprintf("exit %d", entry.ExitCode))
+	}
+	return strings.Join(parts, " • ")
+}
+
+// replaySelection turns a history entry into a picker selection that runs it again
+func replaySelection(entry parser.HistoryEntry) Selection {
+	args := entry.Args
+	if args == nil {
+		args = []string{} // Run without args, as it was
+	}
+	return Selection{Commands: strings.Fields(entry.Command), Args: args, Vars: entry.Vars}
+}
+
+// historyModel is the history view on its own, for imlazy history -i
+type historyModel struct {
+	history  *historyView
+	chosen   *parser.HistoryEntry
+	width    int
+	quitting bool
+}
+
+// Init initializes the model
+func (m historyModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// Update handles messages
+func (m historyModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			m.quitting = true
+			return m, tea.Quit
+		case "enter":
+			if entry, ok := m.history.selected(); ok {
+				m.chosen = &entry
+			}
+			m.quitting = true
+			return m, tea.Quit
+		}
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+	}
+	return m, m.history.update(msg)
+}
+
+// View renders the UI
+func (m historyModel) View() string {
+	if m.quitting {
+		return ""
+	}
+	return m.history.view(m.width)
+}
+
+// RunHistory opens the history view and returns the entry picked to run
+// again. It returns false if the user cancelled.
+func RunHistory(cfg *parser.Config) (parser.HistoryEntry, bool, error) {
+	history, err := newHistoryView(cfg)
+	if err != nil {
+		return parser.HistoryEntry{}, false, err
+	}
+	if len(history.history) == 0 {
+		return parser.HistoryEntry{}, false, fmt.Errorf("no command history found")
+	}
+
+	finalModel, err := tea.NewProgram(historyModel{history: history}).Run()
+	if err != nil {
+		return parser.HistoryEntry{}, false, err
+	}
+	final := finalModel.(historyModel)
+	if final.chosen == nil {
+		return parser.HistoryEntry{}, false, nil
+	}
+	return *final.chosen, true, nil
+}
@@ -0,0 +1,137 @@
+package tui
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Fuzzy scoring weights
+const (
+	scoreMatch       = 16 // Each matched character
+	bonusConsecutive = 12 // Character right after the previous match
+	bonusBoundary    = 10 // Start of a word, e.g. after '-' or '_'
+	bonusSegment     = 14 // Start of a namespace segment, after ':'
+	bonusFirst       = 16 // First character of the name
+	penaltyGap       = 2  // Each skipped character between matches
+	penaltyLeading   = 2  // Each character before the first match
+)
+
+// fuzzyScore scores how well query matches name, case-insensitively. All query
+// characters must appear in name in order. Consecutive characters and matches
+// at namespace segments or word starts score higher, gaps lower. It returns the
+// score and the rune positions of the matched characters in name.
+func fuzzyScore(name, query string) (int, []int, bool) {
+	runes := []rune(strings.ToLower(name))
+	needle := []rune(strings.ToLower(query))
+	if len(needle) == 0 {
+		return 0, nil, true
+	}
+
+	// Try every position of the first character and keep the best greedy match
+	best, found := 0, false
+	var bestPositions []int
+	for start := range runes {
+		if runes[start] != needle[0] {
+			continue
+		}
+		score, positions, ok := scoreFrom(runes, needle, start)
+		if ok && (!found || score > best) {
+			best, bestPositions, found = score, positions, true
+		}
+	}
+	return best, bestPositions, found
+}
+
+// scoreFrom matches needle against runes starting at start, preferring
+// boundary characters for each following match
+func scoreFrom(runes, needle []rune, start int) (int, []int, bool) {
+	positions := []int{start}
+	score := scoreMatch + charBonus(runes, start) - start*penaltyLeading
+
+	prev := start
+	for _, char := range needle[1:] {
+		// Take the next character if it matches; otherwise prefer the first
+		// match at a boundary over the first match at all
+		next := -1
+		if prev+1 < len(runes) && runes[prev+1] == char {
+			next = prev + 1
+		} else {
+			for i := prev + 1; i < len(runes); i++ {
+				if runes[i] != char {
+					continue
+				}
+				if next < 0 {
+					next = i
+				}
+				if charBonus(runes, i) > 0 {
+					next = i
+					break
+				}
+			}
+		}
+		if next < 0 {
+			return 0, nil, false
+		}
+
+		score += scoreMatch
+		if next == prev+1 {
+			score += bonusConsecutive
+		} else {
+			score += charBonus(runes, next) - (next-prev-1)*penaltyGap
+		}
+		positions = append(positions, next)
+		prev = next
+	}
+	return score, positions, true
+}
+
+// charBonus is the bonus for matching the character at i, based on what precedes it
+func charBonus(runes []rune, i int) int {
+	if i == 0 {
+		return bonusFirst
+	}
+	switch prev := runes[i-1]; {
+	case prev == ':':
+		return bonusSegment
+	case prev == '-' || prev == '_' || prev == '.' || prev == '/' || unicode.IsSpace(prev):
+		return bonusBoundary
+	}
+	return 0
+}
+
+// highlight renders name with the characters at positions emphasized
+func highlight(name string, positions []int, style, match lipgloss.Style) string {
+	if len(positions) == 0 {
+		return style.Render(name)
+	}
+	matched := make(map[int]bool, len(positions))
+	for _, pos := range positions {
+		matched[pos] = true
+	}
+
+	var b strings.Builder
+	var run []rune
+	runMatched := false
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		if runMatched {
+			b.WriteString(match.Render(string(run)))
+		} else {
+			b.WriteString(style.Render(string(run)))
+		}
+		run = run[:0]
+	}
+	for i, r := range []rune(name) {
+		if matched[i] != runMatched {
+			flush()
+			runMatched = matched[i]
+		}
+		run = append(run, r)
+	}
+	flush()
+	return b.String()
+}
@@ -0,0 +1,175 @@
+package tui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/javanhut/imlazy/parser"
+)
+
+func TestFuzzyScoreRanking(t *testing.T) {
+	tests := []struct {
+		query string
+		names []string // Best match first
+	}{
+		{"tu", []string{"test:unit", "setup", "status"}},
+		{"dmu", []string{"db:migrate:up", "docs:menu", "demo:run"}},
+		{"build", []string{"build", "ci:build", "rebuild"}},
+		{"dep", []string{"deploy", "db:export", "dev:setup"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			prev := 0
+			for i, name := range tt.names {
+				score, _, ok := fuzzyScore(name, tt.query)
+				if !ok {
+					t.Fatalf("fuzzyScore(%q, %q) did not match", name, tt.query)
+				}
+				if i > 0 && score >= prev {
+					t.Errorf("fuzzyScore(%q, %q) = %d, want less than %q's %d", name, tt.query, score, tt.names[i-1], prev)
+				}
+				prev = score
+			}
+		})
+	}
+}
+
+func TestFuzzyScoreBonuses(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		better, worse string
+	}{
+		{"first character", "u", "unit", "test:unit"},
+		{"segment over boundary", "u", "test:unit", "test-unit"},
+		{"boundary over middle", "u", "test-unit", "testunit"},
+		{"underscore boundary", "r", "db_reset", "dbreset"},
+		{"consecutive over gap", "ts", "tsc", "test"},
+		{"fewer leading characters", "lint", "lint", "golint"},
+		{"segment over earlier middle match", "dm", "db:migrate", "admin:db"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			better, _, okBetter := fuzzyScore(tt.better, tt.query)
+			worse, _, okWorse := fuzzyScore(tt.worse, tt.query)
+			if !okBetter || !okWorse {
+				t.Fatalf("expected %q to match both %q and %q", tt.query, tt.better, tt.worse)
+			}
+			if better <= worse {
+				t.Errorf("%q scores %d for %q and %d for %q, want the first higher", tt.query, better, tt.better, worse, tt.worse)
+			}
+		})
+	}
+}
+
+func TestFuzzyScoreNoMatch(t *testing.T) {
+	tests := []struct {
+		name, query string
+	}{
+		{"build", "bx"},
+		{"test:unit", "ut t"},
+		{"db:migrate:down", "dmu"},
+		{"lint", "tnil"}, // Out of order
+	}
+
+	for _, tt := range tests {
+		if _, _, ok := fuzzyScore(tt.name, tt.query); ok {
+			t.Errorf("fuzzyScore(%q, %q) matched, want no match", tt.name, tt.query)
+		}
+	}
+}
+
+func TestFuzzyScorePositions(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		positions []int
+	}{
+		{"db:migrate:up", "dmu", []int{0, 3, 11}},
+		{"test:unit", "tu", []int{0, 5}},
+		{"Build", "bu", []int{0, 1}},
+		{"lint:fix", "fix", []int{5, 6, 7}},
+		{"anything", "", nil},
+	}
+
+	for _, tt := range tests {
+		_, positions, ok := fuzzyScore(tt.name, tt.query)
+		if !ok {
+			t.Fatalf("fuzzyScore(%q, %q) did not match", tt.name, tt.query)
+		}
+		if !reflect.DeepEqual(positions, tt.positions) {
+			t.Errorf("fuzzyScore(%q, %q) positions = %v, want %v", tt.name, tt.query, positions, tt.positions)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	plain := lipgloss.NewStyle()
+	upper := lipgloss.NewStyle().Transform(strings.ToUpper)
+
+	tests := []struct {
+		name      string
+		positions []int
+		expected  string
+	}{
+		{"db:migrate:up", []int{0, 3, 11}, "Db:Migrate:Up"},
+		{"test:unit", []int{0, 1, 5}, "TEst:Unit"},
+		{"lint:fix", []int{5, 6, 7}, "lint:FIX"},
+		{"build", nil, "build"},
+	}
+
+	for _, tt := range tests {
+		if got := highlight(tt.name, tt.positions, plain, upper); got != tt.expected {
+			t.Errorf("highlight(%q, %v) = %q, want %q", tt.name, tt.positions, got, tt.expected)
+		}
+	}
+}
+
+func TestFrecencyBonusCapped(t *testing.T) {
+	m := initialModel([]parser.CommandInfo{{Name: "lint:stub"}, {Name: "test:unit"}})
+	m.frecency = map[string]float64{"lint:stub": 100}
+
+	// Without a query, frecency alone orders the list
+	m.filterCommands()
+	if m.filtered[0].Name != "lint:stub" {
+		t.Errorf("without a query, first command = %q, want lint:stub", m.filtered[0].Name)
+	}
+
+	// With one, frecency adds at most maxFrecencyBonus, so a better match wins
+	m.textInput.SetValue("tu")
+	m.filterCommands()
+	if len(m.filtered) != 2 || m.filtered[0].Name != "test:unit" {
+		t.Errorf("filtered = %v, want test:unit first", m.filtered)
+	}
+	if !reflect.DeepEqual(m.highlights["test:unit"], []int{0, 5}) {
+		t.Errorf("highlights = %v, want [0 5]", m.highlights["test:unit"])
+	}
+}
+
+func TestDescriptionMatchesRankedBelowNames(t *testing.T) {
+	m := initialModel([]parser.CommandInfo{
+		{Name: "check", Description: "Run the integration tests"},
+		{Name: "maintenance:rotate-logs"},
+		{Name: "test:unit", Description: "Unit tests"},
+	})
+	m.frecency = map[string]float64{"check": 100}
+
+	// "ts" matches maintenance:rotate-logs only weakly, with a negative score,
+	// but a name match still comes before a frequently run description match
+	m.textInput.SetValue("ts")
+	m.filterCommands()
+	var names []string
+	for _, cmd := range m.filtered {
+		names = append(names, cmd.Name)
+	}
+	if want := []string{"test:unit", "maintenance:rotate-logs", "check"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("filtered = %v, want %v", names, want)
+	}
+	if m.highlights["check"] != nil {
+		t.Errorf("description match highlights = %v, want none", m.highlights["check"])
+	}
+}
@@ -0,0 +1,108 @@
+package tui
+
+import (
+	"github.com/javanhut/imlazy/parser"
+)
+
+// row is a line of the picker list: a command, or in tree view a namespace.
+// A name that is both a command and a namespace (test and test:unit) is a
+// single row that runs the command and can be expanded.
+type row struct {
+	info      *parser.CommandInfo // Command on this row, nil for a namespace only
+	namespace string              // Namespace path if the row has children, e.g. "db:migrate"
+	label     string              // Text shown, e.g. "up" for db:migrate:up in tree view
+	depth     int                 // Nesting level in tree view
+	count     int                 // Commands in the namespace
+}
+
+// commands returns what the row runs: its command, or every command in its namespace
+func (r row) commands(cfg *parser.Config) []string {
+	if r.info != nil {
+		return []string{r.info.Name}
+	}
+	return cfg.MatchWildcard(r.namespace + ":*")
+}
+
+// name is the full name of the row's command or namespace
+func (r row) name() string {
+	if r.info != nil {
+		return r.info.Name
+	}
+	return r.namespace
+}
+
+// buildRows lays out the filtered commands as a flat list or, in tree view, as
+// a tree grouped by namespace. While filtering every namespace is expanded.
+func (m *model) buildRows() {
+	m.rows = nil
+	if !m.tree {
+		for i := range m.filtered {
+			m.rows = append(m.rows, row{info: &m.filtered[i], label: m.filtered[i].Name})
+		}
+		return
+	}
+
+	infos := make(map[string]*parser.CommandInfo, len(m.filtered))
+	names := make([]string, len(m.filtered))
+	for i := range m.filtered {
+		infos[m.filtered[i].Name] = &m.filtered[i]
+		names[i] = m.filtered[i].Name
+	}
+
+	var add func(nodes []*parser.CommandNode, depth int)
+	add = func(nodes []*parser.CommandNode, depth int) {
+		for _, node := range nodes {
+			r := row{info: infos[node.Path], label: node.Name, depth: depth}
+			if len(node.Children) > 0 {
+				r.namespace = node.Path
+				r.count = countCommands(node.Children)
+			}
+			m.rows = append(m.rows, r)
+			if r.namespace != "" && (m.expanded[r.namespace] || m.query != "") {
+				add(node.Children, depth+1)
+			}
+		}
+	}
+	add(parser.BuildCommandTree(names), 0)
+}
+
+// countCommands counts the commands in a part of the tree
+func countCommands(nodes []*parser.CommandNode) int {
+	count := 0
+	for _, node := range nodes {
+		if node.Command {
+			count++
+		}
+		count += countCommands(node.Children)
+	}
+	return count
+}
+
+// parentRow returns the index of the namespace row containing row i, or -1
+func (m model) parentRow(i int) int {
+	for j := i - 1; j >= 0; j-- {
+		if m.rows[j].depth < m.rows[i].depth {
+			return j
+		}
+	}
+	return -1
+}
+
+// setExpanded expands or collapses the namespace under the cursor. Collapsing
+// a row without children moves the cursor to its namespace instead.
+func (m *model) setExpanded(expand bool) {
+	if m.cursor >= len(m.rows) {
+		return
+	}
+	r := m.rows[m.cursor]
+	if r.namespace != "" && m.expanded[r.namespace] != expand {
+		m.expanded[r.namespace] = expand
+		m.buildRows()
+		return
+	}
+	if !expand {
+		if parent := m.parentRow(m.cursor); parent >= 0 {
+			m.cursor = parent
+		}
+	}
+}
@@ -2,6 +2,7 @@ package tui
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/textinput"
@@ -29,15 +30,48 @@ var (
 	previewStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("240")).
 			Italic(true)
+
+	chosenStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("42"))
+
+	matchStyle = lipgloss.NewStyle().
+			Bold(true).
+			Underline(true).
+			Foreground(lipgloss.Color("205"))
 )
 
+// maxFrecencyBonus caps how much frecency adds to a fuzzy score, so a close
+// match still beats a popular but poor one
+const maxFrecencyBonus = 20
+
+// Selection is what the user picked in the command picker
+type Selection struct {
+	Commands []string          // Commands to run, in the order they were picked
+	Parallel bool              // Run the commands in parallel instead of one after another
+	Args     []string          // Args entered in the form, nil if the form wasn't shown
+	Vars     map[string]string // Variables given a different value in the form
+}
+
 // model represents the TUI state
 type model struct {
+	cfg         *parser.Config
 	commands    []parser.CommandInfo
 	filtered    []parser.CommandInfo
+	rows        []row // Lines of the list, the cursor points into these
 	cursor      int
+	tree        bool            // Show commands grouped by namespace
+	expanded    map[string]bool // Namespaces expanded in tree view
 	textInput   textinput.Model
-	selected    string
+	chosen      []string // Commands toggled for a multi-command run, in order
+	frecency    map[string]float64
+	highlights  map[string][]int // Matched characters of each command name
+	query       string           // Query the list was last filtered with
+	detailCache map[string]string
+	ranked      bool // The list has been filtered and ranked
+	parallel    bool
+	form        *argsForm    // Shown after picking commands that take input
+	history     *historyView // Shown with ctrl+r to run a past command again
+	selected    Selection
 	quitting    bool
 	windowWidth int
 }
@@ -51,9 +85,12 @@ func initialModel(commands []parser.CommandInfo) model {
 	ti.Width = 40
 
 	return model{
-		commands:  commands,
-		filtered:  commands,
-		textInput: ti,
+		commands:    commands,
+		filtered:    commands,
+		textInput:   ti,
+		highlights:  make(map[string][]int),
+		detailCache: make(map[string]string),
+		expanded:    make(map[string]bool),
 	}
 }
 
@@ -66,6 +103,13 @@ func (m model) Init() tea.Cmd {
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 
+	if m.form != nil {
+		return m.updateForm(msg)
+	}
+	if m.history != nil {
+		return m.updateHistory(msg)
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.String() {
@@ -73,13 +117,66 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.quitting = true
 			return m, tea.Quit
 
-		case "enter":
-			if len(m.filtered) > 0 && m.cursor < len(m.filtered) {
-				m.selected = m.filtered[m.cursor].Name
+		case "enter", "alt+enter":
+			var commands []string
+			if len(m.chosen) > 0 {
+				commands = m.chosen
+			} else if m.cursor < len(m.rows) {
+				commands = m.rows[m.cursor].commands(m.cfg)
+			}
+			if len(commands) == 0 {
+				m.quitting = true
+				return m, tea.Quit
+			}
+			m.selected = Selection{Commands: commands, Parallel: m.parallel && len(commands) > 1}
+
+			// Ask for args and variables first if the commands take any
+			if m.cfg != nil && (msg.String() == "alt+enter" || needsForm(m.cfg, commands)) {
+				m.form = newArgsForm(m.cfg, commands)
+				return m, textinput.Blink
 			}
 			m.quitting = true
 			return m, tea.Quit
 
+		case " ", "tab":
+			// Space is typed into the filter once there is one
+			if msg.String() == " " && m.textInput.Value() != "" {
+				break
+			}
+			if m.cursor < len(m.rows) {
+				m.toggleAll(m.rows[m.cursor].commands(m.cfg))
+				if m.cursor < len(m.rows)-1 {
+					m.cursor++
+				}
+			}
+			return m, nil
+
+		case "ctrl+g":
+			m.tree = !m.tree
+			m.buildRows()
+			m.cursor = 0
+			return m, nil
+
+		case "left", "right":
+			if m.tree {
+				m.setExpanded(msg.String() == "right")
+				return m, nil
+			}
+
+		case "ctrl+t":
+			m.parallel = !m.parallel
+			return m, nil
+
+		case "ctrl+r":
+			if m.cfg != nil {
+				history, err := newHistoryView(m.cfg)
+				if err == nil {
+					m.history = history
+					return m, textinput.Blink
+				}
+			}
+			return m, nil
+
 		case "up", "ctrl+p":
 			if m.cursor > 0 {
 				m.cursor--
@@ -87,7 +184,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 
 		case "down", "ctrl+n":
-			if m.cursor < len(m.filtered)-1 {
+			if m.cursor < len(m.rows)-1 {
 				m.cursor++
 			}
 			return m, nil
@@ -111,58 +208,168 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
-// filterCommands filters the command list based on the search input
-func (m *model) filterCommands() {
-	query := strings.ToLower(m.textInput.Value())
-	if query == "" {
-		m.filtered = m.commands
-		if m.cursor >= len(m.filtered) {
-			m.cursor = 0
+// updateForm handles messages while the args form is shown
+func (m model) updateForm(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "ctrl+c":
+			m.selected = Selection{}
+			m.quitting = true
+			return m, tea.Quit
+		case "esc":
+			m.form = nil
+			m.selected = Selection{}
+			return m, textinput.Blink
+		case "enter":
+			m.selected.Args, m.selected.Vars = m.form.selection()
+			m.quitting = true
+			return m, tea.Quit
 		}
-		return
 	}
+	return m, m.form.update(msg)
+}
 
-	var filtered []parser.CommandInfo
-	for _, cmd := range m.commands {
-		nameLower := strings.ToLower(cmd.Name)
-		descLower := strings.ToLower(cmd.Description)
+// updateHistory handles messages while the history view is shown
+func (m model) updateHistory(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "ctrl+c":
+			m.quitting = true
+			return m, tea.Quit
+		case "esc":
+			m.history = nil
+			return m, textinput.Blink
+		case "enter":
+			if entry, ok := m.history.selected(); ok {
+				m.selected = replaySelection(entry)
+			}
+			m.quitting = true
+			return m, tea.Quit
+		}
+	}
+	if msg, ok := msg.(tea.WindowSizeMsg); ok {
+		m.windowWidth = msg.Width
+	}
+	return m, m.history.update(msg)
+}
 
-		// Match if query appears in name or description
-		if strings.Contains(nameLower, query) || strings.Contains(descLower, query) {
-			filtered = append(filtered, cmd)
-			continue
+// details renders the detail pane for a row, cached per width since it reads
+// the history file
+func (m model) details(r row, width int) string {
+	if r.info == nil {
+		return renderNamespaceDetails(m.cfg, r.namespace, width)
+	}
+	key := fmt.Sprintf("%s/%d", r.info.Name, width)
+	if details, ok := m.detailCache[key]; ok {
+		return details
+	}
+	details := renderDetails(m.cfg, *r.info, width)
+	m.detailCache[key] = details
+	return details
+}
+
+// labelPositions maps matched positions in a full name to the row's label,
+// which in tree view is only the last segment
+func labelPositions(r row, positions []int) []int {
+	offset := len([]rune(r.name())) - len([]rune(r.label))
+	var shifted []int
+	for _, pos := range positions {
+		if pos >= offset {
+			shifted = append(shifted, pos-offset)
 		}
+	}
+	return shifted
+}
 
-		// Fuzzy match: check if all chars appear in order
-		if fuzzyMatch(nameLower, query) {
-			filtered = append(filtered, cmd)
+// toggle adds a command to the multi-command selection, or removes it
+func (m *model) toggle(name string) {
+	for i, chosen := range m.chosen {
+		if chosen == name {
+			m.chosen = append(m.chosen[:i:i], m.chosen[i+1:]...)
+			return
 		}
 	}
+	m.chosen = append(m.chosen, name)
+}
 
-	m.filtered = filtered
-	if m.cursor >= len(m.filtered) {
-		m.cursor = 0
+// toggleAll picks all of the commands, or drops them if they're all picked already
+func (m *model) toggleAll(names []string) {
+	all := m.allChosen(names)
+	for _, name := range names {
+		if m.isChosen(name) == all {
+			m.toggle(name)
+		}
 	}
 }
 
-// fuzzyMatch checks if all characters in needle appear in haystack in order
-func fuzzyMatch(haystack, needle string) bool {
-	hi := 0
-	for _, char := range needle {
-		found := false
-		for hi < len(haystack) {
-			if rune(haystack[hi]) == char {
-				found = true
-				hi++
-				break
+// allChosen reports whether all of the commands are picked
+func (m model) allChosen(names []string) bool {
+	for _, name := range names {
+		if !m.isChosen(name) {
+			return false
+		}
+	}
+	return len(names) > 0
+}
+
+// isChosen reports whether a command is part of the multi-command selection
+func (m model) isChosen(name string) bool {
+	for _, chosen := range m.chosen {
+		if chosen == name {
+			return true
+		}
+	}
+	return false
+}
+
+// filterCommands filters the command list based on the search input and ranks
+// it by fuzzy score and frecency. Without a query, the most used commands come first.
+func (m *model) filterCommands() {
+	query := strings.ToLower(m.textInput.Value())
+	if m.ranked && query == m.query {
+		return
+	}
+	m.query = query
+	m.ranked = true
+
+	type rankedCommand struct {
+		info   parser.CommandInfo
+		byName bool // Matched by name rather than description
+		score  float64
+	}
+	var ranked []rankedCommand
+	for _, cmd := range m.commands {
+		score, positions, ok := fuzzyScore(cmd.Name, query)
+		if !ok {
+			// Fall back to the description, ranked below all name matches
+			if !strings.Contains(strings.ToLower(cmd.Description), query) {
+				continue
 			}
-			hi++
+			score = 0
 		}
-		if !found {
-			return false
+		m.highlights[cmd.Name] = positions
+
+		bonus := m.frecency[cmd.Name]
+		if query != "" && bonus > maxFrecencyBonus {
+			bonus = maxFrecencyBonus
 		}
+		ranked = append(ranked, rankedCommand{cmd, ok, float64(score) + bonus})
 	}
-	return true
+
+	// Commands are sorted by name, which stays the order among equal scores
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].byName != ranked[j].byName {
+			return ranked[i].byName
+		}
+		return ranked[i].score > ranked[j].score
+	})
+
+	m.filtered = make([]parser.CommandInfo, len(ranked))
+	for i, r := range ranked {
+		m.filtered[i] = r.info
+	}
+	m.buildRows()
+	m.cursor = 0
 }
 
 // View renders the UI
@@ -170,6 +377,12 @@ func (m model) View() string {
 	if m.quitting {
 		return ""
 	}
+	if m.form != nil {
+		return m.form.view()
+	}
+	if m.history != nil {
+		return m.history.view(m.windowWidth)
+	}
 
 	var b strings.Builder
 
@@ -194,8 +407,9 @@ func (m model) View() string {
 		start = m.cursor - maxVisible + 1
 	}
 
-	for i := start; i < len(m.filtered) && i < start+maxVisible; i++ {
-		cmd := m.filtered[i]
+	var list strings.Builder
+	for i := start; i < len(m.rows) && i < start+maxVisible; i++ {
+		r := m.rows[i]
 
 		// Cursor indicator
 		cursor := "  "
@@ -205,58 +419,112 @@ func (m model) View() string {
 			style = selectedStyle
 		}
 
-		// Command name and description
-		line := fmt.Sprintf("%s%-20s", cursor, cmd.Name)
-		if cmd.Description != "" {
-			line += fmt.Sprintf(" %s", dimStyle.Render(cmd.Description))
+		// Mark for commands picked for a multi-command run
+		mark := "  "
+		if m.allChosen(r.commands(m.cfg)) {
+			mark = chosenStyle.Render("✓ ")
 		}
 
-		b.WriteString(style.Render(line))
-		b.WriteString("\n")
+		// Tree view indents rows and marks namespaces as expanded or collapsed
+		prefix := ""
+		if m.tree {
+			prefix = strings.Repeat("  ", r.depth)
+			switch {
+			case r.namespace == "":
+				prefix += "  "
+			case m.expanded[r.namespace] || m.query != "":
+				prefix += "▾ "
+			default:
+				prefix += "▸ "
+			}
+		}
+
+		// Name, with matched characters highlighted, and description
+		line := style.Render(prefix) + highlight(r.label, labelPositions(r, m.highlights[r.name()]), style, matchStyle)
+		if pad := 20 - len([]rune(prefix+r.label)); pad > 0 {
+			line += strings.Repeat(" ", pad)
+		}
+		if r.info != nil && r.info.Description != "" {
+			line += " " + dimStyle.Render(r.info.Description)
+		} else if r.namespace != "" {
+			line += " " + dimStyle.Render(fmt.Sprintf("%d commands", r.count))
+		}
+
+		list.WriteString(style.Render(cursor) + mark + line)
+		list.WriteString("\n")
 	}
 
-	// Preview of selected command
-	if len(m.filtered) > 0 && m.cursor < len(m.filtered) {
-		selected := m.filtered[m.cursor]
-		if len(selected.Run) > 0 {
+	// Details of the row under the cursor, next to the list on wide windows
+	// and below it otherwise
+	width := m.windowWidth
+	if width == 0 {
+		width = 80
+	}
+	if m.cursor < len(m.rows) {
+		selected := m.rows[m.cursor]
+		if width >= sideBySideWidth {
+			listWidth := width * 45 / 100
+			left := lipgloss.NewStyle().Width(listWidth).MaxWidth(listWidth).Render(list.String())
+			right := paneStyle.Render(m.details(selected, width-listWidth-3))
+			b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, left, right))
 			b.WriteString("\n")
-			b.WriteString(dimStyle.Render("Run: "))
-			runPreview := strings.Join(selected.Run, " && ")
-			if len(runPreview) > 60 {
-				runPreview = runPreview[:57] + "..."
-			}
-			b.WriteString(previewStyle.Render(runPreview))
+		} else {
+			b.WriteString(list.String())
+			b.WriteString("\n")
+			b.WriteString(m.details(selected, width))
 			b.WriteString("\n")
 		}
+	} else {
+		b.WriteString(list.String())
+	}
 
-		// Show aliases if any
-		if len(selected.Aliases) > 0 {
-			b.WriteString(dimStyle.Render("Aliases: "))
-			b.WriteString(previewStyle.Render(strings.Join(selected.Aliases, ", ")))
-			b.WriteString("\n")
+	// Selection summary
+	if len(m.chosen) > 0 {
+		mode := "one after another"
+		if m.parallel {
+			mode = "in parallel"
 		}
+		b.WriteString("\n")
+		b.WriteString(chosenStyle.Render(fmt.Sprintf("%d selected: %s", len(m.chosen), strings.Join(m.chosen, ", "))))
+		b.WriteString(dimStyle.Render(fmt.Sprintf(" (run %s)", mode)))
+		b.WriteString("\n")
 	}
 
 	// Help
+	parallel := "off"
+	if m.parallel {
+		parallel = "on"
+	}
 	b.WriteString("\n")
-	b.WriteString(dimStyle.Render("↑/↓ navigate • enter select • esc cancel • ctrl+u clear"))
+	keys := "↑/↓ navigate"
+	if m.tree {
+		keys += " • ←/→ collapse/expand"
+	}
+	b.WriteString(dimStyle.Render(fmt.Sprintf("%s • tab select • enter run • alt+enter args • ctrl+t parallel: %s • ctrl+g tree • ctrl+r history • esc cancel • ctrl+u clear", keys, parallel)))
 
 	return b.String()
 }
 
-// RunPicker opens the interactive command picker and returns the selected command
-func RunPicker(cfg *parser.Config) (string, error) {
+// RunPicker opens the interactive command picker and returns the selection.
+// Several commands can be picked with space; parallel sets the initial state of
+// the parallel toggle. Commands that take args or variables get a form to fill
+// in before the picker returns. The selection is empty if the user cancelled.
+func RunPicker(cfg *parser.Config, parallel bool) (Selection, error) {
 	commands := cfg.GetCommandsInfo()
 	if len(commands) == 0 {
-		return "", fmt.Errorf("no commands defined")
+		return Selection{}, fmt.Errorf("no commands defined")
 	}
 
 	m := initialModel(commands)
+	m.cfg = cfg
+	m.parallel = parallel
+	m.frecency = cfg.Frecency()
+	m.filterCommands()
 	p := tea.NewProgram(m)
 
 	finalModel, err := p.Run()
 	if err != nil {
-		return "", err
+		return Selection{}, err
 	}
 
 	final := finalModel.(model)
@@ -0,0 +1,96 @@
+package tui
+
+import (
+	"reflect"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/javanhut/imlazy/parser"
+)
+
+// pickerModel returns a picker listing build, lint and test, in that order
+func pickerModel() model {
+	m := initialModel([]parser.CommandInfo{{Name: "build"}, {Name: "lint"}, {Name: "test"}})
+	m.filterCommands()
+	return m
+}
+
+// press sends key presses to the picker; runes are typed as text
+func press(m model, keys ...tea.KeyMsg) model {
+	for _, key := range keys {
+		updated, _ := m.Update(key)
+		m = updated.(model)
+	}
+	return m
+}
+
+var (
+	keySpace   = tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")}
+	keyTab     = tea.KeyMsg{Type: tea.KeyTab}
+	keyUp      = tea.KeyMsg{Type: tea.KeyUp}
+	keyEnter   = tea.KeyMsg{Type: tea.KeyEnter}
+	keyCtrlT   = tea.KeyMsg{Type: tea.KeyCtrlT}
+	keyLetterT = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("t")}
+)
+
+func TestPickerToggleSelection(t *testing.T) {
+	// Space and tab pick the command under the cursor and move down
+	m := press(pickerModel(), keySpace, keyTab)
+	if want := []string{"build", "lint"}; !reflect.DeepEqual(m.chosen, want) {
+		t.Errorf("chosen = %v, want %v", m.chosen, want)
+	}
+	if m.cursor != 2 {
+		t.Errorf("cursor = %d, want 2", m.cursor)
+	}
+
+	// Toggling a picked command drops it again
+	m = press(m, keyUp, keyTab)
+	if want := []string{"build"}; !reflect.DeepEqual(m.chosen, want) {
+		t.Errorf("chosen after toggling lint off = %v, want %v", m.chosen, want)
+	}
+
+	// Enter runs the picked commands in the order they were picked
+	m = press(m, keyTab, keyEnter)
+	if want := []string{"build", "test"}; !reflect.DeepEqual(m.selected.Commands, want) {
+		t.Errorf("selected = %v, want %v", m.selected.Commands, want)
+	}
+	if m.selected.Parallel {
+		t.Error("commands should run one after another unless ctrl+t was pressed")
+	}
+}
+
+func TestPickerParallel(t *testing.T) {
+	m := press(pickerModel(), keyCtrlT, keySpace, keySpace, keyEnter)
+	if !m.selected.Parallel || len(m.selected.Commands) != 2 {
+		t.Errorf("selection = %+v, want two commands in parallel", m.selected)
+	}
+
+	// Pressing ctrl+t again turns it off
+	m = press(pickerModel(), keyCtrlT, keyCtrlT, keySpace, keySpace, keyEnter)
+	if m.selected.Parallel {
+		t.Error("ctrl+t twice should turn parallel off")
+	}
+
+	// A single command has nothing to run in parallel with
+	m = press(pickerModel(), keyCtrlT, keyEnter)
+	if m.selected.Parallel || !reflect.DeepEqual(m.selected.Commands, []string{"build"}) {
+		t.Errorf("selection = %+v, want build alone", m.selected)
+	}
+}
+
+func TestPickerSpaceInFilter(t *testing.T) {
+	// Once there is filter text, space is typed into it
+	m := press(pickerModel(), keyLetterT, keySpace)
+	if got := m.textInput.Value(); got != "t " {
+		t.Errorf("filter = %q, want %q", got, "t ")
+	}
+	if len(m.chosen) != 0 {
+		t.Errorf("chosen = %v, want nothing picked by space", m.chosen)
+	}
+
+	// Tab still picks
+	m = press(pickerModel(), keyLetterT, keyTab)
+	if want := []string{"test"}; !reflect.DeepEqual(m.chosen, want) {
+		t.Errorf("chosen = %v, want %v picked by tab", m.chosen, want)
+	}
+}
@@ -0,0 +1,46 @@
+package tui
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/muesli/cancelreader"
+	"golang.org/x/term"
+)
+
+var errNotTerminal = errors.New("stdin is not a terminal")
+
+// WatchKeys puts the terminal in cbreak mode and calls read in the background
+// with a reader of the keys pressed: keys arrive without Enter and aren't
+// echoed, while output and Ctrl+C work as usual. The returned function stops
+// reading, making pending reads fail, waits for read to return and restores
+// the terminal.
+func WatchKeys(read func(keys io.Reader)) (func(), error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, errNotTerminal
+	}
+	restore, err := enableCbreak(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := cancelreader.NewReader(os.Stdin)
+	if err != nil {
+		restore()
+		return nil, err
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		read(keys)
+	}()
+
+	return func() {
+		keys.Cancel()
+		<-done
+		keys.Close()
+		restore()
+	}, nil
+}
@@ -0,0 +1,206 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultPollInterval is how often the polling backend scans for changes
+const DefaultPollInterval = 500 * time.Millisecond
+
+// backend delivers file system events for individually registered directories
+type backend interface {
+	Add(dir string) error
+	Remove(dir string) error
+	Events() <-chan fsnotify.Event
+	Errors() <-chan error
+	Close() error
+}
+
+// notifyBackend uses the OS notification API (inotify, kqueue, ...) via fsnotify
+type notifyBackend struct {
+	w *fsnotify.Watcher
+}
+
+func newNotifyBackend() (*notifyBackend, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return &notifyBackend{w: w}, nil
+}
+
+func (n *notifyBackend) Add(dir string) error          { return n.w.Add(dir) }
+func (n *notifyBackend) Remove(dir string) error       { return n.w.Remove(dir) }
+func (n *notifyBackend) Events() <-chan fsnotify.Event { return n.w.Events }
+func (n *notifyBackend) Errors() <-chan error          { return n.w.Errors }
+func (n *notifyBackend) Close() error                  { return n.w.Close() }
+
+// fileState is what the polling backend remembers about a directory entry
+type fileState struct {
+	modTime time.Time
+	size    int64
+	isDir   bool
+}
+
+// pollBackend finds changes by listing the registered directories on an
+// interval. It works where notifications don't: network filesystems, some
+// container bind mounts, or when inotify watches are exhausted.
+type pollBackend struct {
+	interval time.Duration
+	events   chan fsnotify.Event
+	errors   chan error
+	done     chan struct{}
+	once     sync.Once
+
+	mu   sync.Mutex
+	dirs map[string]map[string]fileState // Entries of each registered directory
+}
+
+func newPollBackend(interval time.Duration) *pollBackend {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	p := &pollBackend{
+		interval: interval,
+		events:   make(chan fsnotify.Event),
+		errors:   make(chan error),
+		done:     make(chan struct{}),
+		dirs:     make(map[string]map[string]fileState),
+	}
+	go p.loop()
+	return p
+}
+
+func (p *pollBackend) Add(dir string) error {
+	entries, err := scanDir(dir)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.dirs[dir]; !ok {
+		p.dirs[dir] = entries
+	}
+	return nil
+}
+
+func (p *pollBackend) Remove(dir string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.dirs, dir)
+	return nil
+}
+
+func (p *pollBackend) Events() <-chan fsnotify.Event { return p.events }
+func (p *pollBackend) Errors() <-chan error          { return p.errors }
+
+func (p *pollBackend) Close() error {
+	p.once.Do(func() { close(p.done) })
+	return nil
+}
+
+func (p *pollBackend) loop() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, event := range p.poll() {
+				select {
+				case p.events <- event:
+				case <-p.done:
+					return
+				}
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// poll rescans every registered directory and returns the differences as events
+func (p *pollBackend) poll() []fsnotify.Event {
+	p.mu.Lock()
+	dirs := make([]string, 0, len(p.dirs))
+	for dir := range p.dirs {
+		dirs = append(dirs, dir)
+	}
+	p.mu.Unlock()
+	sort.Strings(dirs)
+
+	var events []fsnotify.Event
+	for _, dir := range dirs {
+		current, err := scanDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			continue // Transient error, try again next time
+		}
+
+		p.mu.Lock()
+		previous, ok := p.dirs[dir]
+		if !ok {
+			p.mu.Unlock()
+			continue // Removed meanwhile
+		}
+		if err != nil {
+			// The directory is gone; everything in it was removed
+			delete(p.dirs, dir)
+			current = nil
+		} else {
+			p.dirs[dir] = current
+		}
+		p.mu.Unlock()
+
+		events = append(events, diffEntries(dir, previous, current)...)
+	}
+	return events
+}
+
+// diffEntries compares two listings of dir
+func diffEntries(dir string, previous, current map[string]fileState) []fsnotify.Event {
+	var events []fsnotify.Event
+	for name, cur := range current {
+		prev, existed := previous[name]
+		path := filepath.Join(dir, name)
+		switch {
+		case !existed || prev.isDir != cur.isDir:
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Create})
+		case !cur.isDir && (!prev.modTime.Equal(cur.modTime) || prev.size != cur.size):
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Write})
+		}
+	}
+	for name := range previous {
+		if _, exists := current[name]; !exists {
+			events = append(events, fsnotify.Event{Name: filepath.Join(dir, name), Op: fsnotify.Remove})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Name < events[j].Name })
+	return events
+}
+
+// scanDir lists the entries of a directory
+func scanDir(dir string) (map[string]fileState, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]fileState, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed while listing
+		}
+		states[entry.Name()] = fileState{
+			modTime: info.ModTime(),
+			size:    info.Size(),
+			isDir:   entry.IsDir(),
+		}
+	}
+	return states, nil
+}
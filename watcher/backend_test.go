@@ -0,0 +1,85 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestPollBackend(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.go")
+	if err := os.WriteFile(existing, []byte("package x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := newPollBackend(20 * time.Millisecond)
+	defer p.Close()
+	if err := p.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for an event on a path, skipping unrelated ones
+	expect := func(path string, op fsnotify.Op) {
+		t.Helper()
+		timeout := time.After(2 * time.Second)
+		for {
+			select {
+			case event := <-p.Events():
+				if event.Name == path && event.Op&op != 0 {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("no %v event for %s", op, path)
+			}
+		}
+	}
+
+	created := filepath.Join(dir, "new.go")
+	os.WriteFile(created, []byte("package x\n"), 0644)
+	expect(created, fsnotify.Create)
+
+	os.WriteFile(existing, []byte("package x\n\nfunc F() {}\n"), 0644)
+	expect(existing, fsnotify.Write)
+
+	os.Remove(created)
+	expect(created, fsnotify.Remove)
+
+	sub := filepath.Join(dir, "sub")
+	os.Mkdir(sub, 0755)
+	expect(sub, fsnotify.Create)
+}
+
+func TestDiffEntries(t *testing.T) {
+	now := time.Now()
+	previous := map[string]fileState{
+		"same.go":    {modTime: now, size: 10},
+		"changed.go": {modTime: now, size: 10},
+		"gone.go":    {modTime: now, size: 10},
+		"dir":        {modTime: now, isDir: true},
+	}
+	current := map[string]fileState{
+		"same.go":    {modTime: now, size: 10},
+		"changed.go": {modTime: now.Add(time.Second), size: 10},
+		"added.go":   {modTime: now, size: 1},
+		"dir":        {modTime: now.Add(time.Second), isDir: true}, // Directory mtimes don't count
+	}
+
+	events := diffEntries("/src", previous, current)
+	expected := []fsnotify.Event{
+		{Name: filepath.Join("/src", "added.go"), Op: fsnotify.Create},
+		{Name: filepath.Join("/src", "changed.go"), Op: fsnotify.Write},
+		{Name: filepath.Join("/src", "gone.go"), Op: fsnotify.Remove},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("diffEntries() = %v, want %v", events, expected)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("event %d = %v, want %v", i, events[i], expected[i])
+		}
+	}
+}
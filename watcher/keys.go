@@ -0,0 +1,58 @@
+package watcher
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/javanhut/imlazy/output"
+)
+
+// PrintKeys shows the keys available in watch mode
+func PrintKeys() {
+	output.PrintInfo("Keys: r re-run | f re-run failed | p pause/resume | c clear | q quit\n")
+}
+
+// ReadKeys reads key presses from r, one byte per key, and acts on them until
+// q is pressed, in which case it returns nil, or reading fails.
+func (w *Watcher) ReadKeys(r io.Reader) error {
+	keys := bufio.NewReader(r)
+	for {
+		key, err := keys.ReadByte()
+		if err != nil {
+			return err
+		}
+		if !w.handleKey(key) {
+			return nil
+		}
+	}
+}
+
+// handleKey acts on a key pressed in watch mode. It returns false once the
+// user asked to quit.
+func (w *Watcher) handleKey(key byte) bool {
+	switch key {
+	case 'r', '\r', '\n':
+		w.Rerun()
+	case 'f':
+		if w.TriggerFailed() == 0 {
+			output.PrintInfo("\nNo failed commands to re-run")
+		}
+	case 'p':
+		if w.Paused() {
+			output.PrintInfo("\nWatching resumed")
+			w.SetPaused(false)
+		} else {
+			w.SetPaused(true)
+			output.PrintWarning("\nWatching paused, press p to resume")
+		}
+	case 'c':
+		clearScreen()
+		PrintKeys()
+		w.PrintStatus()
+	case 'q':
+		return false
+	case '?', 'h':
+		PrintKeys()
+	}
+	return true
+}
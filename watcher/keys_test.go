@@ -0,0 +1,119 @@
+package watcher
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// keyTargets returns a watcher with build and test targets that report their
+// runs on the returned channel
+func keyTargets() (*Watcher, *Target, *Target, chan string) {
+	runs := make(chan string, 10)
+	w, _ := NewWatcher(0)
+	add := func(name string) *Target {
+		return w.Add(name, nil, func(ctx context.Context, changed []string) error {
+			runs <- name
+			return nil
+		})
+	}
+	return w, add("build"), add("test"), runs
+}
+
+// collectRuns returns the names of the targets run within a short time
+func collectRuns(runs chan string) []string {
+	var names []string
+	timeout := time.After(200 * time.Millisecond)
+	for {
+		select {
+		case name := <-runs:
+			names = append(names, name)
+		case <-timeout:
+			return names
+		}
+	}
+}
+
+func TestReadKeysRerun(t *testing.T) {
+	for _, key := range []string{"r", "\n"} {
+		w, _, _, runs := keyTargets()
+		if err := w.ReadKeys(strings.NewReader(key)); err != io.EOF {
+			t.Errorf("ReadKeys(%q) = %v, want EOF", key, err)
+		}
+		if got := collectRuns(runs); len(got) != 2 {
+			t.Errorf("%q ran %v, want both targets", key, got)
+		}
+		w.Stop()
+	}
+}
+
+func TestReadKeysRerunFailed(t *testing.T) {
+	w, _, test, runs := keyTargets()
+	defer w.Stop()
+
+	w.ReadKeys(strings.NewReader("f"))
+	if got := collectRuns(runs); len(got) != 0 {
+		t.Errorf("f without failures ran %v, want nothing", got)
+	}
+
+	test.failed = true
+	w.ReadKeys(strings.NewReader("f"))
+	if got := collectRuns(runs); len(got) != 1 || got[0] != "test" {
+		t.Errorf("f ran %v, want only test", got)
+	}
+}
+
+func TestReadKeysPause(t *testing.T) {
+	w, build, _, runs := keyTargets()
+	defer w.Stop()
+
+	w.ReadKeys(strings.NewReader("p"))
+	if !w.Paused() {
+		t.Fatal("p did not pause watching")
+	}
+
+	// Changes while paused run once watching resumes
+	build.notify("changed", []string{"main.go"}, time.Millisecond)
+	if got := collectRuns(runs); len(got) != 0 {
+		t.Errorf("change while paused ran %v, want nothing", got)
+	}
+	w.ReadKeys(strings.NewReader("p"))
+	if w.Paused() {
+		t.Error("second p did not resume watching")
+	}
+	if got := collectRuns(runs); len(got) != 1 || got[0] != "build" {
+		t.Errorf("resuming ran %v, want build", got)
+	}
+}
+
+func TestReadKeysClear(t *testing.T) {
+	cleared := false
+	defer func(orig func()) { clearScreen = orig }(clearScreen)
+	clearScreen = func() { cleared = true }
+
+	w, _, _, runs := keyTargets()
+	defer w.Stop()
+
+	w.ReadKeys(strings.NewReader("c"))
+	if !cleared {
+		t.Error("c did not clear the screen")
+	}
+	if got := collectRuns(runs); len(got) != 0 {
+		t.Errorf("c ran %v, want nothing", got)
+	}
+}
+
+func TestReadKeysQuit(t *testing.T) {
+	w, _, _, runs := keyTargets()
+	defer w.Stop()
+
+	// Keys after q are not read
+	if err := w.ReadKeys(strings.NewReader("xqr")); err != nil {
+		t.Errorf("ReadKeys() = %v, want nil after q", err)
+	}
+	if got := collectRuns(runs); len(got) != 0 {
+		t.Errorf("keys after q ran %v, want nothing", got)
+	}
+}
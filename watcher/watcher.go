@@ -1,94 +1,189 @@
 package watcher
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/javanhut/imlazy/glob"
+	"github.com/javanhut/imlazy/ignore"
 	"github.com/javanhut/imlazy/output"
 )
 
-// Watcher watches files for changes and triggers callbacks
+// Mode controls what happens when files change while the callback is still running
+type Mode string
+
+const (
+	// ModeQueue lets the current run finish, then runs once more for all changes made meanwhile
+	ModeQueue Mode = "queue"
+	// ModeRestart cancels the current run and starts a fresh one
+	ModeRestart Mode = "restart"
+)
+
+// Watcher watches files for changes and triggers the callbacks of its targets.
+// All targets share a single backend: fsnotify, or polling when requested or
+// when fsnotify can't watch a directory.
 type Watcher struct {
-	patterns     []string
 	debounceTime time.Duration
-	callback     func() error
-	watcher      *fsnotify.Watcher
+	usePolling   bool          // Poll from the start instead of using fsnotify
+	pollInterval time.Duration // Scan interval for the polling backend
+	backendMu    sync.Mutex
+	backend      backend
 	done         chan struct{}
-	mu           sync.Mutex
-	lastEvent    time.Time
+	root         string          // Directory the watch was started from
+	dirs         map[string]bool // Directories registered with the backend
+	targets      []*Target
+	pausedMu     sync.Mutex
+	paused       bool // Changes are collected but don't trigger runs
+	clearScreen  bool // Clear the terminal before each re-run
 }
 
-// NewWatcher creates a new file watcher
-func NewWatcher(patterns []string, debounceMs int, callback func() error) (*Watcher, error) {
-	fsWatcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return nil, err
-	}
+// Target is a callback (usually a command) that reacts to changes matching its
+// own patterns
+type Target struct {
+	name     string
+	patterns []string
+	callback func(ctx context.Context, changed []string) error
+	mode     Mode
+	debounce time.Duration   // Overrides the watcher's debounce time if set
+	ignored  *ignore.Matcher // Paths that never trigger a run or get watched
+	labelled bool            // Prefix messages with the name, set when there are several targets
+	done     chan struct{}   // Closed when the watcher stops
+	w        *Watcher
+
+	mu      sync.Mutex
+	running bool                // A callback is in progress
+	pending bool                // Changes arrived during the current run
+	cancel  context.CancelFunc  // Cancels the current run
+	idle    chan struct{}       // Closed when the run loop exits
+	timer   *time.Timer         // Debounces rapid changes
+	changed map[string]struct{} // Files changed since the last run started
+	stale   bool                // Changes arrived while paused
+	failed  bool                // The last completed run returned an error
+	last    *result             // Outcome of the last completed run
+	started bool                // A run has been started before
+	reason  string              // Why the next run starts, shown once it does
+}
+
+// result is the outcome of a completed run
+type result struct {
+	err      error
+	duration time.Duration
+	finished time.Time
+}
 
-	if debounceMs <= 0 {
-		debounceMs = 300 // default debounce
+// clearScreen clears the terminal; replaced in tests
+var clearScreen = output.ClearScreen
+
+// DefaultDebounce is how long changes must settle before a run when nothing else is configured
+const DefaultDebounce = 300 * time.Millisecond
+
+// NewWatcher creates a new file watcher. Add targets before calling Start.
+func NewWatcher(debounce time.Duration) (*Watcher, error) {
+	if debounce <= 0 {
+		debounce = DefaultDebounce
 	}
 
 	return &Watcher{
-		patterns:     patterns,
-		debounceTime: time.Duration(debounceMs) * time.Millisecond,
-		callback:     callback,
-		watcher:      fsWatcher,
+		debounceTime: debounce,
+		pollInterval: DefaultPollInterval,
+		dirs:         make(map[string]bool),
 		done:         make(chan struct{}),
 	}, nil
 }
 
+// UsePolling makes the watcher scan for changes on an interval instead of using
+// file system notifications. It must be called before Start.
+func (w *Watcher) UsePolling(interval time.Duration) {
+	w.usePolling = true
+	if interval > 0 {
+		w.pollInterval = interval
+	}
+}
+
+// SetPollInterval sets the scan interval used if the watcher falls back to polling
+func (w *Watcher) SetPollInterval(interval time.Duration) {
+	if interval > 0 {
+		w.pollInterval = interval
+	}
+}
+
+// SetClearScreen makes the watcher clear the terminal before runs triggered by
+// changes or re-runs, so only the output of the latest run is visible
+func (w *Watcher) SetClearScreen(clear bool) {
+	w.clearScreen = clear
+}
+
+// Add registers a target that runs callback when files matching patterns change.
+// The callback gets the files (relative to the watch root) changed since the
+// previous run; its context is cancelled when the run is restarted or the
+// watcher is stopped.
+func (w *Watcher) Add(name string, patterns []string, callback func(ctx context.Context, changed []string) error) *Target {
+	t := &Target{
+		name:     name,
+		patterns: patterns,
+		callback: callback,
+		mode:     ModeQueue,
+		done:     w.done,
+		w:        w,
+		changed:  make(map[string]struct{}),
+	}
+	w.targets = append(w.targets, t)
+	return t
+}
+
+// SetMode sets how changes during a run are handled
+func (t *Target) SetMode(mode Mode) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mode = mode
+}
+
+// SetDebounce overrides how long changes must settle before the target runs
+func (t *Target) SetDebounce(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.debounce = d
+}
+
+// SetIgnore sets the rules for paths that are neither watched nor trigger runs
+func (t *Target) SetIgnore(m *ignore.Matcher) {
+	t.ignored = m
+}
+
 // Start begins watching for file changes
 func (w *Watcher) Start() error {
-	// Add directories to watch based on patterns
-	dirs := make(map[string]bool)
 	cwd, err := os.Getwd()
 	if err != nil {
 		return err
 	}
+	w.root = cwd
 
-	for _, pattern := range w.patterns {
-		// If pattern contains **, we need to walk subdirectories
-		if strings.Contains(pattern, "**") {
-			err := filepath.Walk(cwd, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return nil // Skip errors
-				}
-				if info.IsDir() {
-					// Skip hidden directories
-					if strings.HasPrefix(info.Name(), ".") && path != cwd {
-						return filepath.SkipDir
-					}
-					dirs[path] = true
-				}
-				return nil
-			})
-			if err != nil {
-				return err
-			}
-		} else {
-			// Simple pattern - just watch the directory part
-			dir := filepath.Dir(pattern)
-			if dir == "" || dir == "." {
-				dir = cwd
-			} else {
-				dir = filepath.Join(cwd, dir)
-			}
-			if info, err := os.Stat(dir); err == nil && info.IsDir() {
-				dirs[dir] = true
-			}
-		}
+	if w.usePolling {
+		w.backend = newPollBackend(w.pollInterval)
+	} else if nb, err := newNotifyBackend(); err == nil {
+		w.backend = nb
+	} else {
+		output.PrintWarning("Warning: file notifications unavailable (%v), polling every %v instead", err, w.pollInterval)
+		w.backend = newPollBackend(w.pollInterval)
 	}
 
-	// Add all directories to watcher
-	for dir := range dirs {
-		if err := w.watcher.Add(dir); err != nil {
-			output.PrintWarning("Warning: could not watch %s: %v", dir, err)
-		}
+	var patterns []string
+	for _, t := range w.targets {
+		t.patterns = glob.Relative(cwd, t.patterns)
+		t.labelled = len(w.targets) > 1
+		patterns = append(patterns, t.patterns...)
+	}
+
+	// Watch every directory that could contain a matching file
+	for _, root := range glob.Roots(cwd, patterns) {
+		w.addTree(root)
 	}
 
 	// Start watching
@@ -97,43 +192,175 @@ func (w *Watcher) Start() error {
 	return nil
 }
 
-func (w *Watcher) watch() {
-	var timer *time.Timer
-	var timerMu sync.Mutex
+// addDir registers a single directory with the backend. If fsnotify can't
+// watch it (e.g. the inotify watch limit is reached) the watcher switches to polling.
+func (w *Watcher) addDir(dir string) {
+	if w.dirs[dir] {
+		return
+	}
+	if err := w.backend.Add(dir); err != nil {
+		if _, polling := w.backend.(*pollBackend); polling {
+			output.PrintWarning("Warning: could not watch %s: %v", dir, err)
+			return
+		}
+		output.PrintWarning("Warning: could not watch %s (%v), polling every %v instead", dir, err, w.pollInterval)
+		w.switchToPolling()
+		if err := w.backend.Add(dir); err != nil {
+			output.PrintWarning("Warning: could not watch %s: %v", dir, err)
+			return
+		}
+	}
+	w.dirs[dir] = true
+}
+
+// switchToPolling replaces the fsnotify backend with a polling one watching the
+// same directories
+func (w *Watcher) switchToPolling() {
+	poll := newPollBackend(w.pollInterval)
+	for dir := range w.dirs {
+		if err := poll.Add(dir); err != nil {
+			delete(w.dirs, dir)
+		}
+	}
+
+	w.backendMu.Lock()
+	old := w.backend
+	w.backend = poll
+	w.backendMu.Unlock()
+	old.Close()
+}
+
+// currentBackend returns the backend, which may change when falling back to polling
+func (w *Watcher) currentBackend() backend {
+	w.backendMu.Lock()
+	defer w.backendMu.Unlock()
+	return w.backend
+}
+
+// addTree registers root and the directories below it that could contain a
+// matching file. It returns the files found, so changes made before the watch
+// was in place aren't missed.
+func (w *Watcher) addTree(root string) []string {
+	var files []string
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip errors
+		}
+		if info.IsDir() {
+			if path != root && !w.watchable(path) {
+				return filepath.SkipDir
+			}
+			w.addDir(path)
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	return files
+}
+
+// watchable reports whether dir could contain a file matching some target
+func (w *Watcher) watchable(dir string) bool {
+	rel := w.relPath(dir)
+	for _, t := range w.targets {
+		if glob.CouldMatchBelow(t.patterns, rel) && !t.ignored.Match(dir, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// relPath returns path relative to the watch root, with forward slashes
+func (w *Watcher) relPath(path string) string {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// removeTree forgets dir and every directory below it. fsnotify drops the
+// watches of deleted directories itself.
+func (w *Watcher) removeTree(dir string) {
+	prefix := dir + string(filepath.Separator)
+	for watched := range w.dirs {
+		if watched == dir || strings.HasPrefix(watched, prefix) {
+			w.backend.Remove(watched)
+			delete(w.dirs, watched)
+		}
+	}
+}
 
+// changedPaths inspects an event, keeps the set of watched directories up to
+// date and returns the file paths that may trigger a run
+func (w *Watcher) changedPaths(event fsnotify.Event) []string {
+	switch {
+	case event.Op&fsnotify.Create != 0:
+		// New directories (including ones moved in) are watched too
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if !w.watchable(event.Name) {
+				return nil
+			}
+			return w.addTree(event.Name)
+		}
+	case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+		// Deleted or moved-away directories are no longer watched
+		if w.dirs[event.Name] {
+			w.removeTree(event.Name)
+			return nil
+		}
+	case event.Op&fsnotify.Write == 0:
+		// Ignore chmod-only events
+		return nil
+	}
+
+	// Editors that save via rename-swap produce a Rename of the old file and a
+	// Create of the new one; either matching the patterns triggers a run
+	return []string{event.Name}
+}
+
+func (w *Watcher) watch() {
 	for {
+		b := w.currentBackend()
 		select {
-		case event, ok := <-w.watcher.Events:
+		case event, ok := <-b.Events():
 			if !ok {
+				if b != w.currentBackend() {
+					continue // Closed after switching to polling
+				}
 				return
 			}
 
-			// Only handle write and create events
-			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+			paths := w.changedPaths(event)
+			if len(paths) == 0 {
 				continue
 			}
 
-			// Check if file matches any pattern
-			if !w.matchesPattern(event.Name) {
-				continue
+			verb := "changed"
+			if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				if _, err := os.Stat(event.Name); os.IsNotExist(err) {
+					verb = "removed"
+				}
 			}
 
-			// Debounce rapid events
-			timerMu.Lock()
-			if timer != nil {
-				timer.Stop()
-			}
-			timer = time.AfterFunc(w.debounceTime, func() {
-				output.PrintInfo("\nFile changed: %s", event.Name)
-				output.PrintInfo("Re-running command...")
-				if err := w.callback(); err != nil {
-					output.PrintError("Error: %v", err)
+			// Each target only reacts to its own patterns
+			for _, t := range w.targets {
+				var matched []string
+				for _, path := range paths {
+					if rel := w.relPath(path); t.matches(path, rel) {
+						matched = append(matched, rel)
+					}
 				}
-			})
-			timerMu.Unlock()
+				if len(matched) > 0 {
+					t.notify(verb, matched, w.debounceTime)
+				}
+			}
 
-		case err, ok := <-w.watcher.Errors:
+		case err, ok := <-b.Errors():
 			if !ok {
+				if b != w.currentBackend() {
+					continue
+				}
 				return
 			}
 			output.PrintError("Watcher error: %v", err)
@@ -144,57 +371,306 @@ func (w *Watcher) watch() {
 	}
 }
 
-// matchesPattern checks if a file path matches any of the watch patterns
-func (w *Watcher) matchesPattern(path string) bool {
-	cwd, _ := os.Getwd()
-	relPath, err := filepath.Rel(cwd, path)
-	if err != nil {
-		relPath = path
+// TriggerAll runs every target, e.g. for the initial run
+func (w *Watcher) TriggerAll() {
+	for _, t := range w.targets {
+		t.Trigger()
 	}
+}
 
-	for _, pattern := range w.patterns {
-		// Handle ** glob
-		if strings.Contains(pattern, "**") {
-			// Convert ** pattern to a simpler check
-			parts := strings.Split(pattern, "**")
-			if len(parts) == 2 {
-				prefix := strings.TrimSuffix(parts[0], "/")
-				suffix := strings.TrimPrefix(parts[1], "/")
+// matches reports whether a changed file is relevant to the target
+func (t *Target) matches(path, rel string) bool {
+	return glob.MatchAny(t.patterns, rel) && !t.ignored.Match(path, false)
+}
 
-				// Check if path starts with prefix (if any)
-				if prefix != "" && !strings.HasPrefix(relPath, prefix) {
-					continue
-				}
+// notify records changed files and triggers a run once changes have settled
+// for the debounce time
+func (t *Target) notify(verb string, paths []string, debounce time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-				// Check if path ends with suffix pattern
-				if suffix != "" {
-					matched, _ := filepath.Match(suffix, filepath.Base(relPath))
-					if matched {
-						return true
-					}
-				} else {
-					return true
-				}
-			}
+	for _, path := range paths {
+		t.changed[path] = struct{}{}
+	}
+	if t.debounce > 0 {
+		debounce = t.debounce
+	}
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	t.timer = time.AfterFunc(debounce, func() {
+		if t.w.Paused() {
+			t.mu.Lock()
+			t.stale = true
+			t.mu.Unlock()
+			return
+		}
+		t.mu.Lock()
+		t.reason = fmt.Sprintf("File %s: %s", verb, paths[0])
+		t.mu.Unlock()
+		t.Trigger()
+	})
+}
+
+// Paused reports whether changes currently trigger runs
+func (w *Watcher) Paused() bool {
+	w.pausedMu.Lock()
+	defer w.pausedMu.Unlock()
+	return w.paused
+}
+
+// SetPaused pauses or resumes triggering runs. Changes made while paused are
+// kept, and the targets they affect run once watching resumes.
+func (w *Watcher) SetPaused(paused bool) {
+	w.pausedMu.Lock()
+	w.paused = paused
+	w.pausedMu.Unlock()
+
+	if paused {
+		return
+	}
+	for _, t := range w.targets {
+		t.mu.Lock()
+		stale := t.stale
+		t.stale = false
+		t.mu.Unlock()
+		if stale {
+			t.Trigger()
+		}
+	}
+}
+
+// TriggerFailed re-runs the targets whose last run failed and returns how many there were
+func (w *Watcher) TriggerFailed() int {
+	var failed []*Target
+	for _, t := range w.targets {
+		t.mu.Lock()
+		if t.failed {
+			failed = append(failed, t)
+		}
+		t.mu.Unlock()
+	}
+	for _, t := range failed {
+		t.Trigger()
+	}
+	return len(failed)
+}
+
+// Trigger runs the callback, or handles an in-progress run according to the mode:
+// queue mode schedules exactly one follow-up run, restart mode cancels the
+// current run and starts a new one as soon as it has stopped.
+func (t *Target) Trigger() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	select {
+	case <-t.done:
+		return
+	default:
+	}
+
+	if t.running {
+		t.pending = true
+		if t.mode == ModeRestart && t.cancel != nil {
+			t.printInfo("Restarting command...")
+			t.cancel()
 		} else {
-			// Simple glob matching
-			matched, _ := filepath.Match(pattern, relPath)
-			if matched {
-				return true
-			}
-			// Also try matching just the filename
-			matched, _ = filepath.Match(pattern, filepath.Base(relPath))
-			if matched {
-				return true
+			t.printInfo("Command still running, queued another run")
+		}
+		return
+	}
+
+	t.running = true
+	t.idle = make(chan struct{})
+	go t.runLoop()
+}
+
+// runLoop runs the callback until no more changes are pending. Output of a
+// new run is separated from the previous one only once the run starts, so a
+// queued run doesn't clear the output of the one still in progress.
+func (t *Target) runLoop() {
+	for rerun := false; ; rerun = true {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.mu.Lock()
+		t.pending = false
+		t.cancel = cancel
+		changed := make([]string, 0, len(t.changed))
+		for path := range t.changed {
+			changed = append(changed, path)
+		}
+		t.changed = make(map[string]struct{})
+		reason, started := t.reason, t.started
+		t.reason, t.started = "", true
+		t.mu.Unlock()
+		sort.Strings(changed)
+
+		if started {
+			t.w.beforeRerun(t)
+		}
+		if reason != "" {
+			t.printInfo("%s", reason)
+		}
+		if rerun {
+			t.printInfo("Re-running command...")
+		} else {
+			t.printInfo("Running command...")
+		}
+
+		start := time.Now()
+		err := t.callback(ctx, changed)
+		cancelled := ctx.Err() != nil
+		cancel()
+		if !cancelled {
+			if err != nil {
+				t.printError("Error: %v", err)
 			}
+			t.mu.Lock()
+			t.failed = err != nil
+			t.last = &result{err: err, duration: time.Since(start), finished: time.Now()}
+			t.mu.Unlock()
+			t.w.PrintStatus()
+		}
+
+		t.mu.Lock()
+		stopped := false
+		select {
+		case <-t.done:
+			stopped = true
+		default:
 		}
+		if !t.pending || stopped {
+			t.running = false
+			t.cancel = nil
+			close(t.idle)
+			t.mu.Unlock()
+			return
+		}
+		t.mu.Unlock()
+	}
+}
+
+// beforeRerun separates the output of a new run from the previous one, clearing
+// the terminal if requested. The terminal isn't cleared while another target
+// is running, so its output isn't lost.
+func (w *Watcher) beforeRerun(self *Target) {
+	if w.clearScreen && !w.othersRunning(self) {
+		clearScreen()
+	} else {
+		fmt.Println()
 	}
+}
 
+// othersRunning reports whether a target other than self has a run in progress
+func (w *Watcher) othersRunning(self *Target) bool {
+	for _, t := range w.targets {
+		if t == self {
+			continue
+		}
+		t.mu.Lock()
+		running := t.running
+		t.mu.Unlock()
+		if running {
+			return true
+		}
+	}
 	return false
 }
 
-// Stop stops the watcher
+// Rerun runs every target again, clearing the terminal first if requested
+func (w *Watcher) Rerun() {
+	w.TriggerAll()
+}
+
+// StatusLine summarizes the last completed run, e.g. "✓ Passed in 1.2s at
+// 15:04:05". With several targets it lists the result of each one. It is
+// empty until a run has completed.
+func (w *Watcher) StatusLine() string {
+	var parts []string
+	var latest time.Time
+	for _, t := range w.targets {
+		t.mu.Lock()
+		last, running := t.last, t.running
+		t.mu.Unlock()
+
+		if last != nil && last.finished.After(latest) {
+			latest = last.finished
+		}
+		if len(w.targets) == 1 {
+			if last == nil {
+				return ""
+			}
+			duration := last.duration.Round(time.Millisecond)
+			if last.err != nil {
+				parts = append(parts, output.Error("✗ Failed in %v", duration))
+			} else {
+				parts = append(parts, output.Success("✓ Passed in %v", duration))
+			}
+			break
+		}
+
+		switch {
+		case last == nil && running:
+			parts = append(parts, output.Info("… %s", t.name))
+		case last == nil:
+			parts = append(parts, output.Header("- %s", t.name))
+		case last.err != nil:
+			parts = append(parts, output.Error("✗ %s %v", t.name, last.duration.Round(time.Millisecond)))
+		default:
+			parts = append(parts, output.Success("✓ %s %v", t.name, last.duration.Round(time.Millisecond)))
+		}
+	}
+	if latest.IsZero() {
+		return ""
+	}
+	return strings.Join(parts, "  ") + output.Header(" at %s", latest.Format("15:04:05"))
+}
+
+// PrintStatus prints the status line, if a run has completed
+func (w *Watcher) PrintStatus() {
+	if status := w.StatusLine(); status != "" {
+		fmt.Println(status)
+	}
+}
+
+// printInfo prints an info message, labelled with the target name if needed
+func (t *Target) printInfo(format string, args ...interface{}) {
+	if t.labelled {
+		fmt.Print(output.Prefix(t.name))
+	}
+	output.PrintInfo(format, args...)
+}
+
+// printError prints an error message, labelled with the target name if needed
+func (t *Target) printError(format string, args ...interface{}) {
+	if t.labelled {
+		fmt.Fprint(os.Stderr, output.Prefix(t.name))
+	}
+	output.PrintError(format, args...)
+}
+
+// Stop stops the watcher, cancelling any runs in progress and waiting for them to exit
 func (w *Watcher) Stop() {
 	close(w.done)
-	w.watcher.Close()
+
+	var idle []chan struct{}
+	for _, t := range w.targets {
+		t.mu.Lock()
+		if t.timer != nil {
+			t.timer.Stop()
+		}
+		if t.running && t.cancel != nil {
+			t.cancel()
+		}
+		if t.idle != nil {
+			idle = append(idle, t.idle)
+		}
+		t.mu.Unlock()
+	}
+
+	if b := w.currentBackend(); b != nil {
+		b.Close()
+	}
+	for _, ch := range idle {
+		<-ch
+	}
 }
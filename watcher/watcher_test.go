@@ -0,0 +1,360 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/javanhut/imlazy/output"
+)
+
+func TestStatusLine(t *testing.T) {
+	output.SetColorsEnabled(false)
+	defer output.SetColorsEnabled(true)
+
+	noop := func(ctx context.Context, changed []string) error { return nil }
+	finished := time.Date(2024, 1, 2, 15, 4, 5, 0, time.Local)
+
+	w, _ := NewWatcher(0)
+	build := w.Add("build", nil, noop)
+	if got := w.StatusLine(); got != "" {
+		t.Errorf("StatusLine() before any run = %q, want empty", got)
+	}
+
+	build.last = &result{duration: 1234 * time.Millisecond, finished: finished}
+	if got, want := w.StatusLine(), "✓ Passed in 1.234s at 15:04:05"; got != want {
+		t.Errorf("StatusLine() = %q, want %q", got, want)
+	}
+
+	build.last.err = errors.New("exit status 1")
+	if got, want := w.StatusLine(), "✗ Failed in 1.234s at 15:04:05"; got != want {
+		t.Errorf("StatusLine() = %q, want %q", got, want)
+	}
+
+	// With several targets each one is listed, along with the latest finish time
+	test := w.Add("test", nil, noop)
+	lint := w.Add("lint", nil, noop)
+	test.last = &result{duration: 20 * time.Millisecond, finished: finished.Add(time.Second)}
+	lint.running = true
+	got := w.StatusLine()
+	for _, want := range []string{"✗ build 1.234s", "✓ test 20ms", "… lint", "at 15:04:06"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("StatusLine() = %q, missing %q", got, want)
+		}
+	}
+}
+
+func TestWatcherBackends(t *testing.T) {
+	for _, polling := range []bool{false, true} {
+		name := "fsnotify"
+		if polling {
+			name = "polling"
+		}
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Chdir(dir)
+			write := func(rel, content string) {
+				t.Helper()
+				if err := os.WriteFile(filepath.Join(dir, rel), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			write("main.go", "package main\n")
+
+			runs := make(chan []string, 10)
+			w, _ := NewWatcher(20 * time.Millisecond)
+			if polling {
+				w.UsePolling(20 * time.Millisecond)
+			}
+			w.Add("build", []string{"**/*.go"}, func(ctx context.Context, changed []string) error {
+				runs <- changed
+				return nil
+			})
+			if err := w.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer w.Stop()
+
+			// Wait for a run that was given path as changed
+			expect := func(step, path string) {
+				t.Helper()
+				timeout := time.After(3 * time.Second)
+				for {
+					select {
+					case changed := <-runs:
+						if slices.Contains(changed, path) {
+							return
+						}
+					case <-timeout:
+						t.Fatalf("%s: no run for %s", step, path)
+					}
+				}
+			}
+
+			write("main.go", "package main\n\nfunc main() {}\n")
+			expect("write", "main.go")
+
+			// A directory created after startup is watched, including files
+			// written before its watch was in place
+			if err := os.Mkdir(filepath.Join(dir, "pkg"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			write("pkg/a.go", "package pkg\n")
+			expect("new directory", "pkg/a.go")
+			write("pkg/b.go", "package pkg\n")
+			expect("file in new directory", "pkg/b.go")
+
+			if err := os.Rename(filepath.Join(dir, "pkg", "b.go"), filepath.Join(dir, "pkg", "c.go")); err != nil {
+				t.Fatal(err)
+			}
+			expect("rename", "pkg/b.go")
+
+			if err := os.Remove(filepath.Join(dir, "pkg", "c.go")); err != nil {
+				t.Fatal(err)
+			}
+			expect("remove", "pkg/c.go")
+
+			// Editors save by writing a temporary file and renaming it over the original
+			write("main.go.tmp", "package main\n\nfunc main() { println() }\n")
+			if err := os.Rename(filepath.Join(dir, "main.go.tmp"), filepath.Join(dir, "main.go")); err != nil {
+				t.Fatal(err)
+			}
+			expect("atomic save", "main.go")
+
+			// A removed directory is no longer watched, and one created again
+			// in its place is
+			if err := os.RemoveAll(filepath.Join(dir, "pkg")); err != nil {
+				t.Fatal(err)
+			}
+			expect("remove directory", "pkg/a.go")
+			if err := os.MkdirAll(filepath.Join(dir, "pkg", "sub"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			write("pkg/sub/d.go", "package sub\n")
+			expect("recreated directory", "pkg/sub/d.go")
+		})
+	}
+}
+
+func TestClearScreenWhenRunStarts(t *testing.T) {
+	var clears atomic.Int32
+	defer func(orig func()) { clearScreen = orig }(clearScreen)
+	clearScreen = func() { clears.Add(1) }
+
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	w, _ := NewWatcher(0)
+	w.SetClearScreen(true)
+	build := w.Add("build", nil, func(ctx context.Context, changed []string) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	defer w.Stop()
+
+	// The first run doesn't clear anything
+	build.Trigger()
+	<-started
+	if n := clears.Load(); n != 0 {
+		t.Errorf("first run cleared the screen %d times, want 0", n)
+	}
+
+	// A change during the run only queues another one, leaving its output alone
+	build.notify("changed", []string{"main.go"}, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	if n := clears.Load(); n != 0 {
+		t.Errorf("queueing a run cleared the screen %d times, want 0", n)
+	}
+
+	// The screen is cleared once the queued run starts
+	release <- struct{}{}
+	<-started
+	if n := clears.Load(); n != 1 {
+		t.Errorf("queued run cleared the screen %d times, want 1", n)
+	}
+	release <- struct{}{}
+}
+
+func TestQueueModeRunsOnceMore(t *testing.T) {
+	runs := make(chan []string, 10)
+	release := make(chan struct{})
+	w, _ := NewWatcher(0)
+	build := w.Add("build", nil, func(ctx context.Context, changed []string) error {
+		runs <- changed
+		<-release
+		return nil
+	})
+	defer w.Stop()
+
+	build.Trigger()
+	<-runs
+
+	// Any number of changes during the run collapse into one follow-up run
+	for _, path := range []string{"a.go", "b.go", "c.go"} {
+		build.notify("changed", []string{path}, time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+	}
+	build.Trigger()
+	release <- struct{}{}
+
+	select {
+	case changed := <-runs:
+		if want := []string{"a.go", "b.go", "c.go"}; !slices.Equal(changed, want) {
+			t.Errorf("follow-up run got %v, want %v", changed, want)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("no follow-up run")
+	}
+	release <- struct{}{}
+
+	select {
+	case changed := <-runs:
+		t.Errorf("unexpected third run with %v", changed)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRestartModeCancelsRun(t *testing.T) {
+	runs := make(chan context.Context, 10)
+	w, _ := NewWatcher(0)
+	server := w.Add("server", nil, func(ctx context.Context, changed []string) error {
+		runs <- ctx
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	server.SetMode(ModeRestart)
+
+	server.Trigger()
+	first := <-runs
+
+	// A new trigger cancels the running callback and starts it again
+	server.Trigger()
+	select {
+	case <-first.Done():
+	case <-time.After(3 * time.Second):
+		t.Fatal("running callback was not cancelled")
+	}
+	select {
+	case second := <-runs:
+		if second.Err() != nil {
+			t.Error("restarted run began with a cancelled context")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("callback was not started again")
+	}
+
+	// Stopping cancels the current run and waits for it
+	w.Stop()
+	if len(runs) != 0 {
+		t.Errorf("%d more runs started, want none", len(runs))
+	}
+	if server.last != nil {
+		t.Error("cancelled runs should not be recorded as completed")
+	}
+}
+
+// captureStdout redirects os.Stdout until the returned function is called,
+// which restores it and returns everything written meanwhile. It is restored
+// at the end of the test in any case.
+func captureStdout(t *testing.T) func() string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	var buf strings.Builder
+	done := make(chan struct{})
+	go func() {
+		io.Copy(&buf, r)
+		close(done)
+	}()
+	restore := func() string {
+		os.Stdout = orig
+		w.Close()
+		<-done
+		return buf.String()
+	}
+	t.Cleanup(func() { restore() })
+	return restore
+}
+
+func TestSharedSessionTargets(t *testing.T) {
+	output.SetColorsEnabled(false)
+	defer output.SetColorsEnabled(true)
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	os.Mkdir(filepath.Join(dir, "docs"), 0755)
+
+	type run struct {
+		target  string
+		changed []string
+	}
+	runs := make(chan run, 10)
+	w, _ := NewWatcher(20 * time.Millisecond)
+	for _, target := range []struct {
+		name     string
+		patterns []string
+	}{
+		{"build", []string{"**/*.go"}},
+		{"docs", []string{"docs/**/*.md"}},
+	} {
+		name := target.name
+		w.Add(name, target.patterns, func(ctx context.Context, changed []string) error {
+			runs <- run{name, changed}
+			return nil
+		})
+	}
+
+	stdout := captureStdout(t)
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each change runs only the target whose patterns match it
+	expect := func(path, target string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, path), []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if target != "" {
+			select {
+			case r := <-runs:
+				if r.target != target || !slices.Equal(r.changed, []string{path}) {
+					t.Errorf("writing %s ran %s with %v, want %s", path, r.target, r.changed, target)
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatalf("writing %s ran nothing", path)
+			}
+		}
+		select {
+		case r := <-runs:
+			t.Errorf("writing %s also ran %s", path, r.target)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	expect("main.go", "build")
+	expect("docs/guide.md", "docs")
+	expect("README.md", "") // Matches neither
+	w.Stop()
+
+	// Messages are labelled with the target they belong to
+	got := stdout()
+	for _, want := range []string{"[build] File changed: main.go", "[docs] File changed: docs/guide.md"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "[build] File changed: docs/guide.md") || strings.Contains(got, "README.md") {
+		t.Errorf("output mentions a change for the wrong target:\n%s", got)
+	}
+}